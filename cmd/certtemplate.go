@@ -0,0 +1,208 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// certTemplate holds the subject, subject alternative names and extra extensions for the csr command, read from a
+// YAML file given by --template, for example
+//
+//	subject:
+//	  commonName: device-1234
+//	  organization: [DIMO]
+//	dnsNames: [device-1234.devices.dimo.zone]
+//	extensions:
+//	  - id: 1.3.6.1.4.1.99999.1
+//	    value: 0c0474657374
+type certTemplate struct {
+	Subject struct {
+		CommonName         string   `yaml:"commonName"`
+		SerialNumber       string   `yaml:"serialNumber"`
+		Organization       []string `yaml:"organization"`
+		OrganizationalUnit []string `yaml:"organizationalUnit"`
+		Country            []string `yaml:"country"`
+		Province           []string `yaml:"province"`
+		Locality           []string `yaml:"locality"`
+	} `yaml:"subject"`
+	DNSNames       []string `yaml:"dnsNames"`
+	IPAddresses    []string `yaml:"ipAddresses"`
+	EmailAddresses []string `yaml:"emailAddresses"`
+	URIs           []string `yaml:"uris"`
+
+	// Extensions are added as is, with hex encoded DER values
+	Extensions []struct {
+		ID       string `yaml:"id"`
+		Critical bool   `yaml:"critical"`
+		Value    string `yaml:"value"`
+	} `yaml:"extensions"`
+}
+
+var templateFile string
+var subject string
+var dnsNames []string
+var ipAddresses []string
+var emailAddresses []string
+var uris []string
+
+// addTemplateFlags adds the flags read by loadCertTemplate to cmd.
+func addTemplateFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&templateFile, "template", "", "YAML file with the subject, SANs and extensions")
+	cmd.Flags().StringVar(&subject, "subject", "", "Subject, e.g. 'CN=device-1234,O=DIMO,C=US'. Overrides the "+
+		"template subject.")
+	cmd.Flags().StringSliceVar(&dnsNames, "dns", nil, "DNS subject alternative names")
+	cmd.Flags().StringSliceVar(&ipAddresses, "ip", nil, "IP address subject alternative names")
+	cmd.Flags().StringSliceVar(&emailAddresses, "email", nil, "Email subject alternative names")
+	cmd.Flags().StringSliceVar(&uris, "uri-san", nil, "URI subject alternative names")
+}
+
+// loadCertTemplate reads --template, if given, and adds the subject and SANs from the command line.
+func loadCertTemplate() (*certTemplate, error) {
+	var t certTemplate
+	if templateFile != "" {
+		data, err := os.ReadFile(templateFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(data, &t); err != nil {
+			return nil, fmt.Errorf("invalid template %s: %w", templateFile, err)
+		}
+	}
+
+	t.DNSNames = append(t.DNSNames, dnsNames...)
+	t.IPAddresses = append(t.IPAddresses, ipAddresses...)
+	t.EmailAddresses = append(t.EmailAddresses, emailAddresses...)
+	t.URIs = append(t.URIs, uris...)
+
+	return &t, nil
+}
+
+// subjectName returns --subject if given, otherwise the template subject.
+func (t *certTemplate) subjectName() (pkix.Name, error) {
+	if subject != "" {
+		return parseSubject(subject)
+	}
+
+	s := t.Subject
+	return pkix.Name{
+		CommonName:         s.CommonName,
+		SerialNumber:       s.SerialNumber,
+		Organization:       s.Organization,
+		OrganizationalUnit: s.OrganizationalUnit,
+		Country:            s.Country,
+		Province:           s.Province,
+		Locality:           s.Locality,
+	}, nil
+}
+
+func (t *certTemplate) ips() ([]net.IP, error) {
+	var res []net.IP
+	for _, s := range t.IPAddresses {
+		ip := net.ParseIP(s)
+		if ip == nil {
+			return nil, fmt.Errorf("invalid IP address '%s'", s)
+		}
+		res = append(res, ip)
+	}
+	return res, nil
+}
+
+func (t *certTemplate) parsedURIs() ([]*url.URL, error) {
+	var res []*url.URL
+	for _, s := range t.URIs {
+		u, err := url.Parse(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid URI '%s': %w", s, err)
+		}
+		res = append(res, u)
+	}
+	return res, nil
+}
+
+func (t *certTemplate) extensions() ([]pkix.Extension, error) {
+	var res []pkix.Extension
+	for _, e := range t.Extensions {
+		id, err := parseOID(e.ID)
+		if err != nil {
+			return nil, err
+		}
+		value, err := hex.DecodeString(e.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for extension %s: %w", e.ID, err)
+		}
+		res = append(res, pkix.Extension{Id: id, Critical: e.Critical, Value: value})
+	}
+	return res, nil
+}
+
+// parseSubject parses a comma separated list of CN, SERIALNUMBER, O, OU, C, ST and L attributes. Escaped commas are
+// not supported.
+func parseSubject(s string) (pkix.Name, error) {
+	var name pkix.Name
+	for _, rdn := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(rdn), "=")
+		if !ok {
+			return pkix.Name{}, fmt.Errorf("invalid subject attribute '%s'", rdn)
+		}
+
+		switch strings.ToUpper(key) {
+		case "CN":
+			name.CommonName = value
+		case "SERIALNUMBER":
+			name.SerialNumber = value
+		case "O":
+			name.Organization = append(name.Organization, value)
+		case "OU":
+			name.OrganizationalUnit = append(name.OrganizationalUnit, value)
+		case "C":
+			name.Country = append(name.Country, value)
+		case "ST":
+			name.Province = append(name.Province, value)
+		case "L":
+			name.Locality = append(name.Locality, value)
+		default:
+			return pkix.Name{}, fmt.Errorf("unsupported subject attribute '%s'", key)
+		}
+	}
+	return name, nil
+}
+
+func parseOID(s string) (asn1.ObjectIdentifier, error) {
+	var oid asn1.ObjectIdentifier
+	for _, part := range strings.Split(s, ".") {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("invalid OID '%s'", s)
+		}
+		oid = append(oid, n)
+	}
+	if len(oid) < 2 {
+		return nil, fmt.Errorf("invalid OID '%s'", s)
+	}
+	return oid, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+)
+
+// envPrefix is the prefix of the environment variables which override the config file, e.g. EDGE_IDENTITY_LIB.
+const envPrefix = "EDGE_IDENTITY_"
+
+// configSettings are the config file keys, which are also the names of the flags they provide defaults for.
+// pin-source, a file holding the PIN, has no flag of its own.
+var configSettings = []string{"lib", "token", "serial", "label", "keyid", "pin-file", "pin-env", "pin-fd",
+	"pin-command", "pin-source"}
+
+// config is the YAML config file, for example
+//
+//	lib: /usr/lib/softhsm/libsofthsm2.so
+//	token: dimo
+//	label: clitest
+//	pin-file: /etc/edge-identity/pin
+type config map[string]string
+
+// configPaths returns the locations searched for a config file when --config is not given, in order.
+func configPaths() []string {
+	var paths []string
+	if dir, err := os.UserConfigDir(); err == nil {
+		paths = append(paths, filepath.Join(dir, "edge-identity", "config.yaml"))
+	}
+	return append(paths, "/etc/edge-identity/config.yaml")
+}
+
+// loadConfig reads the config file given by --config or EDGE_IDENTITY_CONFIG, or else the first found in
+// configPaths. An empty config is returned if there is none.
+func loadConfig(cmd *cobra.Command) (config, error) {
+	path := cfgFile
+	if !cmd.Flags().Changed("config") {
+		path = os.Getenv(envPrefix + "CONFIG")
+	}
+
+	if path == "" {
+		for _, p := range configPaths() {
+			if _, err := os.Stat(p); err == nil {
+				path = p
+				break
+			}
+		}
+	}
+	if path == "" {
+		return config{}, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg config
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+
+	for key := range cfg {
+		if !isConfigSetting(key) {
+			return nil, fmt.Errorf("unknown setting '%s' in config file %s", key, path)
+		}
+	}
+
+	return cfg, nil
+}
+
+// applyConfig uses the config file, overridden by EDGE_IDENTITY_* environment variables, for the flags which were
+// not given on the command line or by --uri.
+func applyConfig(cmd *cobra.Command) error {
+	cfg, err := loadConfig(cmd)
+	if err != nil {
+		return err
+	}
+
+	for _, setting := range configSettings {
+		value := cfg[setting]
+		if env, ok := os.LookupEnv(envName(setting)); ok {
+			value = env
+		}
+		if value == "" {
+			continue
+		}
+
+		if setting == "pin-source" {
+			if pinSource == "" {
+				pinSource = value
+			}
+			continue
+		}
+
+		// A PIN given on the command line replaces any PIN source in the config
+		if strings.HasPrefix(setting, "pin-") && pinFlagGiven(cmd) {
+			continue
+		}
+
+		if cmd.Flags().Lookup(setting) == nil || cmd.Flags().Changed(setting) {
+			continue
+		}
+		if err := cmd.Flags().Set(setting, value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// envName returns the environment variable for a setting, e.g. EDGE_IDENTITY_PIN_SOURCE for pin-source.
+func envName(setting string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(setting, "-", "_"))
+}
+
+func isConfigSetting(key string) bool {
+	for _, s := range configSettings {
+		if key == s {
+			return true
+		}
+	}
+	return false
+}
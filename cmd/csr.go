@@ -0,0 +1,108 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"os"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// csrCmd represents the csr command
+var csrCmd = &cobra.Command{
+	Use:   "csr",
+	Short: "Create a PKCS#10 certificate signing request for a key pair",
+	Long: `Creates a PEM encoded PKCS#10 certificate signing request for a secp256k1, P-256, P-384, Ed25519 or RSA
+key pair on the token, signed with its private key. secp256k1 requests are signed with ecdsa-with-SHA256. The subject
+and SANs are taken from the command line and/or a YAML template, which can also add extensions.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doCSR(cmd)
+	},
+}
+
+var csrOut string
+
+func init() {
+	rootCmd.AddCommand(csrCmd)
+
+	csrCmd.Flags().StringVar(&label, "label", "", "Label of the key pair")
+	csrCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the key pair")
+	csrCmd.Flags().StringVar(&csrOut, "out", "", "File to write the CSR to, instead of stdout")
+	addTemplateFlags(csrCmd)
+}
+
+func doCSR(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	t, err := loadCertTemplate()
+	handleError(err)
+
+	request := &x509.CertificateRequest{
+		DNSNames:       t.DNSNames,
+		EmailAddresses: t.EmailAddresses,
+	}
+	request.Subject, err = t.subjectName()
+	handleError(err)
+	request.IPAddresses, err = t.ips()
+	handleError(err)
+	request.URIs, err = t.parsedURIs()
+	handleError(err)
+	request.ExtraExtensions, err = t.extensions()
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	signer, err := p11Token.Signer(labelToUse, keyIdToUse)
+	handleError(err)
+
+	der, err := p11.CreateCertificateRequest(request, signer)
+	handleError(err)
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der})
+	if csrOut != "" {
+		handleError(os.WriteFile(csrOut, encoded, 0644))
+	}
+
+	printResult(csrResult{CSR: string(encoded)}, func() {
+		if csrOut == "" {
+			os.Stdout.Write(encoded)
+		}
+	})
+}
+
+// csrResult is the result of the csr command.
+type csrResult struct {
+	CSR string `json:"csr" yaml:"csr"`
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/spf13/cobra"
+)
+
+// decryptCmd represents the decrypt command
+var decryptCmd = &cobra.Command{
+	Use:   "decrypt",
+	Short: "Decrypt data with an AES key, or an ECIES message with an EC key pair",
+	Long: `Decrypts an envelope written by encrypt, from a file or stdin, with an AES key on the token. With --ecies, decrypts
+a message encrypted to a secp256k1 or P-256 key pair by go-ethereum's crypto/ecies, running only the ECDH step on
+the token.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doDecrypt(cmd)
+	},
+}
+
+var ecies bool
+
+func init() {
+	rootCmd.AddCommand(decryptCmd)
+
+	decryptCmd.Flags().StringVar(&label, "label", "", "Label of the AES key, or EC key pair with --ecies")
+	decryptCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the AES key, or EC key pair with --ecies")
+	decryptCmd.Flags().StringVar(&aad, "aad", "", "Additional authenticated data given to encrypt")
+	decryptCmd.Flags().BoolVar(&ecies, "ecies", false, "Decrypt an ECIES message with an EC key pair")
+	decryptCmd.Flags().StringVar(&inFile, "in", "-", "File to decrypt, or - for stdin")
+	decryptCmd.Flags().StringVar(&outFile, "out", "", "File to write the plaintext to, instead of stdout")
+}
+
+func doDecrypt(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	if ecies && aad != "" {
+		handleError(errors.New("--aad is not supported with --ecies"))
+	}
+
+	envelope, err := readInput(inFile)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	var plaintext []byte
+	if ecies {
+		plaintext, err = p11Token.DecryptECIES(labelToUse, keyIdToUse, envelope, nil, nil)
+	} else {
+		plaintext, err = p11Token.Decrypt(labelToUse, keyIdToUse, envelope, []byte(aad))
+	}
+	handleError(err)
+
+	writeOutput(plaintext, cipherResult{Data: base64.StdEncoding.EncodeToString(plaintext)})
+}
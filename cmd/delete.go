@@ -18,7 +18,6 @@ package cmd
 import (
 	"log"
 
-	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/spf13/cobra"
 )
 
@@ -40,21 +39,37 @@ func init() {
 		"Labels of keys to keep\nexample: --keep foo --keep bar --keep baz")
 }
 
+// deleteResult is the result of the delete command.
+type deleteResult struct {
+	Deleted []string `json:"deleted" yaml:"deleted"`
+}
+
 func doDelete(cmd *cobra.Command) {
-	if len(keysToKeep) > 0 {
-		log.Println("Deleting keys except:")
-		for _, k := range keysToKeep {
-			log.Println("- " + k)
+	if outputFormat == outputText {
+		if len(keysToKeep) > 0 {
+			log.Println("Deleting keys except:")
+			for _, k := range keysToKeep {
+				log.Println("- " + k)
+			}
+		} else {
+			log.Println("Deleting all keys on token")
 		}
-	} else {
-		log.Println("Deleting all keys on token")
 	}
 
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 
 	defer p11Token.Finalise()
-	handleError(p11Token.DeleteAllExcept(keysToKeep))
+	deleted, err := p11Token.DeleteAllExcept(keysToKeep)
+	handleError(err)
+	if deleted == nil {
+		deleted = []string{}
+	}
 
-	log.Println("Finished.")
+	printResult(deleteResult{Deleted: deleted}, func() {
+		for _, l := range deleted {
+			log.Printf("Deleted key with label '%s'", l)
+		}
+		log.Println("Finished.")
+	})
 }
@@ -0,0 +1,102 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// deriveCmd represents the derive command
+var deriveCmd = &cobra.Command{
+	Use:   "derive",
+	Short: "Derive an AES key by ECDH with a peer public key",
+	Long: `Runs ECDH on the token between an EC private key and a peer's public key on the same curve, given as PEM or DER,
+a JWK or a hex encoded point, and stores an AES key derived from the shared secret with the ANSI X9.63 SHA-256 KDF
+and the optional --shared-info. --raw stores the first keysize bits of the shared secret instead, for tokens without
+KDF support. The key is non-extractable unless --extractable is given. Keys generated before ECDH support need
+enableDerive first.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doDerive(cmd)
+	},
+}
+
+var peerFile string
+var derivedLabel string
+var derivedKeysize int
+var extractable bool
+var sharedInfo string
+var rawSecret bool
+
+func init() {
+	rootCmd.AddCommand(deriveCmd)
+
+	deriveCmd.Flags().StringVar(&label, "label", "", "Label of the EC key pair")
+	deriveCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the EC key pair")
+	deriveCmd.Flags().StringVar(&peerFile, "peer", "", "File holding the peer public key, or - for stdin [required]")
+	deriveCmd.Flags().StringVar(&derivedLabel, "derived-label", "", "Label for the derived AES key [required]")
+	deriveCmd.Flags().IntVar(&derivedKeysize, "keysize", 256, "Size of the derived AES key (128, 192 or 256)")
+	deriveCmd.Flags().BoolVar(&extractable, "extractable", false, "Allow the derived key to be wrapped")
+	deriveCmd.Flags().StringVar(&sharedInfo, "shared-info", "", "Hex encoded shared info for the KDF")
+	deriveCmd.Flags().BoolVar(&rawSecret, "raw", false, "Use the truncated shared secret as the key, without a KDF")
+
+	deriveCmd.MarkFlagRequired("peer")
+	deriveCmd.MarkFlagRequired("derived-label")
+}
+
+func doDerive(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	if rawSecret && sharedInfo != "" {
+		handleError(errors.New("--shared-info cannot be used with --raw"))
+	}
+
+	info, err := hex.DecodeString(sharedInfo)
+	if err != nil {
+		handleError(fmt.Errorf("invalid --shared-info: %w", err))
+	}
+
+	peer, err := readInput(peerFile)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	handleError(p11Token.DeriveKey(labelToUse, keyIdToUse, peer, derivedLabel, derivedKeysize, info, rawSecret,
+		extractable))
+
+	printResult(keyResult{Label: derivedLabel}, func() {
+		log.Println("Key derived successfully")
+	})
+}
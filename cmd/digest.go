@@ -0,0 +1,78 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// digestCmd represents the digest command
+var digestCmd = &cobra.Command{
+	Use:   "digest",
+	Short: "Hash a file on the token",
+	Long: `Hashes a file, or stdin, inside the token with SHA-256, SHA-384, SHA-512 or SHA3, streaming large files through
+the token in chunks. Keccak-256 is not a standard PKCS #11 mechanism; if the token has it as a vendor mechanism, give
+its number (see mechs) as --alg.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doDigest(cmd)
+	},
+}
+
+var digestAlgorithm string
+
+func init() {
+	rootCmd.AddCommand(digestCmd)
+
+	digestCmd.Flags().StringVar(&digestAlgorithm, "alg", "sha256", "Algorithm: sha256, sha384, sha512, sha3-256, "+
+		"sha3-384, sha3-512 or a vendor mechanism number")
+	digestCmd.Flags().StringVar(&inFile, "in", "-", "File to hash, or - for stdin")
+}
+
+func doDigest(cmd *cobra.Command) {
+
+	mechanism, err := p11.ParseDigestMechanism(digestAlgorithm)
+	handleError(err)
+
+	var in io.Reader = os.Stdin
+	if inFile != "" && inFile != "-" {
+		f, err := os.Open(inFile)
+		handleError(err)
+		defer f.Close()
+		in = f
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	digest, err := p11Token.Digest(mechanism, in)
+	handleError(err)
+
+	printResult(digestResult{Digest: hex.EncodeToString(digest)}, func() {
+		fmt.Println(hex.EncodeToString(digest))
+	})
+}
+
+// digestResult is the result of the digest command.
+type digestResult struct {
+	Digest string `json:"digest" yaml:"digest"`
+}
@@ -0,0 +1,69 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/spf13/cobra"
+)
+
+// enableDeriveCmd represents the enableDerive command
+var enableDeriveCmd = &cobra.Command{
+	Use:   "enableDerive",
+	Short: "Allow an existing EC key to be used for ECDH",
+	Long: `Sets CKA_DERIVE on an EC private key, so that derive and decrypt --ecies can use it. Keys generated before ECDH
+support was added do not have it set. Some tokens do not allow the attribute to be changed, in which case the key
+has to be regenerated.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doEnableDerive(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(enableDeriveCmd)
+
+	enableDeriveCmd.Flags().StringVar(&label, "label", "", "Label of the EC key pair")
+	enableDeriveCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the EC key pair")
+}
+
+func doEnableDerive(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	handleError(p11Token.EnableDerive(labelToUse, keyIdToUse))
+
+	printResult(keyResult{Label: labelToUse, KeyID: keyIdToUse}, func() {
+		log.Println("Key can now be used for ECDH")
+	})
+}
@@ -0,0 +1,112 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// encryptCmd represents the encrypt command
+var encryptCmd = &cobra.Command{
+	Use:   "encrypt",
+	Short: "Encrypt data with an AES key",
+	Long: `Encrypts a file, or stdin, with an AES key on the token using AES-GCM, AES-CBC with PKCS #7 padding or
+AES-CTR and a random IV. The output is an envelope of a version byte (1), a mode byte (1 GCM, 2 CBC-PAD, 3 CTR), an
+IV length byte, the IV and the ciphertext, which for GCM ends with the 16 byte tag.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doEncrypt(cmd)
+	},
+}
+
+var cipherMode string
+var aad string
+var inFile string
+var outFile string
+
+func init() {
+	rootCmd.AddCommand(encryptCmd)
+
+	encryptCmd.Flags().StringVar(&label, "label", "", "Label of the AES key")
+	encryptCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the AES key")
+	encryptCmd.Flags().StringVar(&cipherMode, "mode", string(p11.ModeGCM), "Mode: gcm, cbc-pad or ctr")
+	encryptCmd.Flags().StringVar(&aad, "aad", "", "Additional authenticated data for gcm")
+	encryptCmd.Flags().StringVar(&inFile, "in", "-", "File to encrypt, or - for stdin")
+	encryptCmd.Flags().StringVar(&outFile, "out", "", "File to write the envelope to, instead of stdout")
+}
+
+func doEncrypt(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	mode, err := p11.ParseCipherMode(cipherMode)
+	handleError(err)
+
+	plaintext, err := readInput(inFile)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	envelope, err := p11Token.Encrypt(labelToUse, keyIdToUse, mode, plaintext, []byte(aad))
+	handleError(err)
+
+	writeOutput(envelope, cipherResult{Data: base64.StdEncoding.EncodeToString(envelope)})
+}
+
+// readInput reads the whole of path, or stdin if path is - or empty.
+func readInput(path string) ([]byte, error) {
+	if path == "" || path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// writeOutput writes data to --out, or to stdout for text output, and prints result for JSON and YAML output.
+func writeOutput(data []byte, result interface{}) {
+	if outFile != "" {
+		handleError(os.WriteFile(outFile, data, 0600))
+	}
+
+	printResult(result, func() {
+		if outFile == "" {
+			os.Stdout.Write(data)
+		}
+	})
+}
+
+// cipherResult is the result of the encrypt and decrypt commands, with the output base64 encoded.
+type cipherResult struct {
+	Data string `json:"data" yaml:"data"`
+}
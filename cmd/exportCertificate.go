@@ -0,0 +1,112 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// exportCertificateCmd represents the exportCertificate command
+var exportCertificateCmd = &cobra.Command{
+	Use:   "exportCertificate",
+	Short: "Export X.509 certificates from the token",
+	Long: `Exports the X.509 certificates with the given label and/or key id, as PEM or, for a single
+certificate, DER.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doExportCertificate(cmd)
+	},
+}
+
+var certificateFormat string
+var certificateOut string
+
+func init() {
+	rootCmd.AddCommand(exportCertificateCmd)
+
+	exportCertificateCmd.Flags().StringVar(&label, "label", "", "Label of the certificate")
+	exportCertificateCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the certificate")
+	exportCertificateCmd.Flags().StringVar(&certificateFormat, "format", "pem", "Format: pem or der")
+	exportCertificateCmd.Flags().StringVar(&certificateOut, "out", "", "File to write to, instead of stdout")
+}
+
+func doExportCertificate(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	certs, err := p11Token.ListCertificates(labelToUse, keyIdToUse)
+	handleError(err)
+	if len(certs) == 0 {
+		handleError(errors.New("no matching certificates found"))
+	}
+
+	var encoded []byte
+	switch certificateFormat {
+	case "pem":
+		for _, c := range certs {
+			encoded = append(encoded, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: c.Raw})...)
+		}
+	case "der":
+		if len(certs) > 1 {
+			handleError(errors.New("more than 1 matching certificate found, use pem for several certificates"))
+		}
+		encoded = certs[0].Raw
+	default:
+		handleError(fmt.Errorf("unknown certificate format '%s', must be pem or der", certificateFormat))
+	}
+
+	if certificateOut != "" {
+		handleError(os.WriteFile(certificateOut, encoded, 0644))
+	}
+
+	result := certificateResult{Format: certificateFormat, Certificate: string(encoded)}
+	if certificateFormat == "der" {
+		result.Certificate = base64.StdEncoding.EncodeToString(encoded)
+	}
+
+	printResult(result, func() {
+		if certificateOut == "" {
+			os.Stdout.Write(encoded)
+		}
+	})
+}
+
+// certificateResult is the result of the exportCertificate command. DER certificates are base64 encoded.
+type certificateResult struct {
+	Format      string `json:"format" yaml:"format"`
+	Certificate string `json:"certificate" yaml:"certificate"`
+}
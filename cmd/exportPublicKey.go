@@ -0,0 +1,99 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+	"os"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// exportPublicKeyCmd represents the exportPublicKey command
+var exportPublicKeyCmd = &cobra.Command{
+	Use:   "exportPublicKey",
+	Short: "Export the public key of an EC, Ed25519 or RSA key pair",
+	Long: `Exports the public key of an EC (secp256k1, P-256, P-384), Ed25519 or RSA key pair as a
+SubjectPublicKeyInfo (pem or der), a JSON Web Key (jwk), an OpenSSH authorized_keys line (ssh) or a hex encoded EC
+point (hex or hex-compressed).`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doExportPublicKey(cmd)
+	},
+}
+
+var publicKeyFormat string
+var publicKeyOut string
+
+func init() {
+	rootCmd.AddCommand(exportPublicKeyCmd)
+
+	exportPublicKeyCmd.Flags().StringVar(&label, "label", "", "Label of the key pair")
+	exportPublicKeyCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the key pair")
+	exportPublicKeyCmd.Flags().StringVar(&publicKeyFormat, "format", string(p11.PublicKeyPEM),
+		"Format: pem, der, jwk, ssh, hex or hex-compressed")
+	exportPublicKeyCmd.Flags().StringVar(&publicKeyOut, "out", "", "File to write the key to, instead of stdout")
+}
+
+func doExportPublicKey(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	pub, err := p11Token.PublicKey(labelToUse, keyIdToUse)
+	handleError(err)
+
+	format := p11.PublicKeyFormat(publicKeyFormat)
+	encoded, err := p11.ExportPublicKey(pub, format)
+	handleError(err)
+
+	if publicKeyOut != "" {
+		handleError(os.WriteFile(publicKeyOut, encoded, 0644))
+	}
+
+	result := publicKeyResult{Format: publicKeyFormat, PublicKey: string(encoded)}
+	if format == p11.PublicKeyDER {
+		result.PublicKey = base64.StdEncoding.EncodeToString(encoded)
+	}
+
+	printResult(result, func() {
+		if publicKeyOut == "" {
+			os.Stdout.Write(encoded)
+		}
+	})
+}
+
+// publicKeyResult is the result of the exportPublicKey command. DER keys are base64 encoded.
+type publicKeyResult struct {
+	Format    string `json:"format" yaml:"format"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
+}
@@ -16,7 +16,8 @@
 package cmd
 
 import (
-	"github.com/DIMO-Network/edge-identity/p11"
+	"log"
+
 	"github.com/spf13/cobra"
 )
 
@@ -27,7 +28,7 @@ var algorithm string
 // generateCmd represents the generate command
 var generateKeyPair = &cobra.Command{
 	Use:   "generateKeyPair",
-	Short: "Generate a new SECP256K1 key pair",
+	Short: "Generate a new EC or RSA key pair, or an AES or HMAC key",
 	Run: func(cmd *cobra.Command, args []string) {
 		doGenerateKeyPair(cmd)
 	},
@@ -38,9 +39,10 @@ func init() {
 
 	generateKeyPair.Flags().StringVar(&label, "label", "", "Label for generated key [required]")
 	generateKeyPair.Flags().StringVar(&keyid, "keyid", "", "KeyId for generated key [required]")
-	generateKeyPair.Flags().StringVar(&keytype, "keytype", "", "Key type for generated key (RSA or AES) [required]")
-	generateKeyPair.Flags().IntVar(&keysize, "keysize", 0, "Size of generated key (AES 128,192,256 - RSA 1024,2048,3072,4096) [required]")
-	generateKeyPair.Flags().StringVar(&algorithm, "algorithm", "", "Algorithm to use, such as S256 [required]")
+	generateKeyPair.Flags().StringVar(&keytype, "keytype", "", "Key type for generated key (EC, RSA, AES or GENERIC for HMAC) [required]")
+	generateKeyPair.Flags().IntVar(&keysize, "keysize", 0, "Size of generated key (AES 128,192,256 - "+
+		"GENERIC 128,256,384,512 - RSA 1024,2048,3072,4096 - EC 256, or 384 for P384) [required]")
+	generateKeyPair.Flags().StringVar(&algorithm, "algorithm", "", "Curve for EC keys: S256 (secp256k1), P256, P384 or Ed25519 [required]")
 	generateKeyPair.MarkFlagRequired("label")
 	generateKeyPair.MarkFlagRequired("keytype")
 	generateKeyPair.MarkFlagRequired("keysize")
@@ -64,9 +66,13 @@ func doGenerateKeyPair(cmd *cobra.Command) {
 		algorithmToUse = algorithm
 	}
 
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 
 	defer p11Token.Finalise()
 	handleError(p11Token.GenerateKeyPair(labelToUse, keyIdToUse, algorithmToUse, keytype, keysize))
+
+	printResult(keyResult{Label: labelToUse, KeyID: keyIdToUse}, func() {
+		log.Printf("Key \"%s\" generated on token", labelToUse)
+	})
 }
@@ -18,7 +18,7 @@ package cmd
 import (
 	"log"
 
-	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/common/hexutil"
 	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/cobra"
 )
@@ -54,12 +54,20 @@ func doGetEthereumAddress(cmd *cobra.Command) {
 		keyIdToUse = keyid
 	}
 
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 	defer p11Token.Finalise()
-	pubKey, _, err := p11Token.GetPublicKey(labelToUse, keyIdToUse)
+	pubKey, keyBytes, err := p11Token.GetPublicKey(labelToUse, keyIdToUse)
 	handleError(err)
 	addr := crypto.PubkeyToAddress(*pubKey)
-	log.Println("Address:", addr)
 
+	printResult(addressResult{Address: addr.Hex(), PublicKey: hexutil.Encode(keyBytes)}, func() {
+		log.Println("Address:", addr)
+	})
+}
+
+// addressResult is the result of the getEthereumAddress command.
+type addressResult struct {
+	Address   string `json:"address" yaml:"address"`
+	PublicKey string `json:"publicKey" yaml:"publicKey"`
 }
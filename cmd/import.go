@@ -16,16 +16,17 @@
 package cmd
 
 import (
+	"fmt"
 	"log"
+	"strings"
 
-	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/spf13/cobra"
 )
 
 // importCmd represents the import command
 var importCmd = &cobra.Command{
 	Use:   "import",
-	Short: "Imports an AES key",
+	Short: "Imports an AES or generic secret (HMAC) key",
 	Long:  ``,
 	Run: func(cmd *cobra.Command, args []string) {
 		doImport(cmd)
@@ -36,24 +37,41 @@ var key []byte
 var label string
 var message string
 var hash string
+var personal bool
 
 func init() {
 	rootCmd.AddCommand(importCmd)
 
 	importCmd.Flags().BytesHexVar(&key, "key", nil, "Plaintext key [required]")
 	importCmd.Flags().StringVar(&label, "label", "", "Label for imported key [required]")
+	importCmd.Flags().StringVar(&keytype, "keytype", "AES", "Key type: AES, or GENERIC for HMAC")
 
 	importCmd.MarkFlagRequired("label")
 	importCmd.MarkFlagRequired("key")
 }
 
 func doImport(cmd *cobra.Command) {
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 
 	defer p11Token.Finalise()
 
-	handleError(p11Token.ImportKey(key, label))
+	switch strings.ToUpper(keytype) {
+	case "AES":
+		handleError(p11Token.ImportKey(key, label))
+	case "GENERIC":
+		handleError(p11Token.ImportGenericSecret(key, label))
+	default:
+		handleError(fmt.Errorf("invalid key type: %s", keytype))
+	}
 
-	log.Println("Key imported successfully")
+	printResult(keyResult{Label: label}, func() {
+		log.Println("Key imported successfully")
+	})
+}
+
+// keyResult is the result of the commands creating a key.
+type keyResult struct {
+	Label string `json:"label" yaml:"label"`
+	KeyID string `json:"keyid,omitempty" yaml:"keyid,omitempty"`
 }
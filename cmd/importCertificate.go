@@ -0,0 +1,120 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+// importCertificateCmd represents the importCertificate command
+var importCertificateCmd = &cobra.Command{
+	Use:   "importCertificate",
+	Short: "Import an X.509 certificate for a key pair",
+	Long: `Imports an X.509 certificate, optionally followed by its chain, from a PEM or DER file. The certificate
+must be for the key pair with the given label and/or key id, and is stored with the same label and CKA_ID. Chain
+certificates are stored with the labels <label>-chain-1, <label>-chain-2 and so on.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doImportCertificate(cmd)
+	},
+}
+
+var certificateFile string
+
+func init() {
+	rootCmd.AddCommand(importCertificateCmd)
+
+	importCertificateCmd.Flags().StringVar(&label, "label", "", "Label of the key pair")
+	importCertificateCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the key pair")
+	importCertificateCmd.Flags().StringVar(&certificateFile, "file", "", "PEM or DER certificate file [required]")
+
+	importCertificateCmd.MarkFlagRequired("file")
+}
+
+func doImportCertificate(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	data, err := os.ReadFile(certificateFile)
+	handleError(err)
+
+	chain, err := parseCertificates(data)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	imported, err := p11Token.ImportCertificates(labelToUse, keyIdToUse, chain)
+	handleError(err)
+
+	result := certificateImportResult{Imported: imported, Skipped: len(chain) - imported}
+	printResult(result, func() {
+		log.Printf("Imported %d certificate(s), skipped %d already on the token", result.Imported, result.Skipped)
+	})
+}
+
+// parseCertificates returns the DER encoded certificates in a PEM file, or in a file of DER certificates. They are not
+// parsed with the x509 package, which rejects certificates for secp256k1 keys.
+func parseCertificates(data []byte) ([][]byte, error) {
+	var certs [][]byte
+	block, rest := pem.Decode(data)
+	if block == nil {
+		for rest = data; len(rest) > 0; {
+			var cert asn1.RawValue
+			var err error
+			if rest, err = asn1.Unmarshal(rest, &cert); err != nil {
+				return nil, fmt.Errorf("invalid DER certificate: %w", err)
+			}
+			certs = append(certs, cert.FullBytes)
+		}
+	}
+
+	for ; block != nil; block, rest = pem.Decode(rest) {
+		if block.Type == "CERTIFICATE" {
+			certs = append(certs, block.Bytes)
+		}
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("no certificates found")
+	}
+	return certs, nil
+}
+
+// certificateImportResult is the result of the importCertificate command.
+type certificateImportResult struct {
+	Imported int `json:"imported" yaml:"imported"`
+	Skipped  int `json:"skipped" yaml:"skipped"`
+}
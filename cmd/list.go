@@ -33,18 +33,27 @@ func init() {
 	rootCmd.AddCommand(listCmd)
 
 	listCmd.Flags().StringVar(&label, "label", "", "Show results for this label only")
+	listCmd.Flags().StringVar(&keyid, "keyid", "", "Show results for this key id only")
 }
 
 func doList(cmd *cobra.Command) {
 
-	var labelToUse *string
-	if cmd.Flags().Changed("label") {
-		labelToUse = &label
-	}
-
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 
 	defer p11Token.Finalise()
-	handleError(p11Token.PrintObjects(labelToUse))
+
+	filter := p11.ObjectFilter{Label: label, ID: keyid}
+	if p11URI != nil {
+		filter.Class = p11URI.Class()
+	}
+
+	if outputFormat == outputText {
+		handleError(p11Token.PrintObjects(filter))
+		return
+	}
+
+	objects, err := p11Token.ListObjects(filter)
+	handleError(err)
+	printResult(objects, nil)
 }
@@ -0,0 +1,80 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// listCertificatesCmd represents the listCertificates command
+var listCertificatesCmd = &cobra.Command{
+	Use:   "listCertificates",
+	Short: "List X.509 certificates on the token",
+	Long: `Lists the X.509 certificates on the token with their subject, issuer, validity and whether they match
+the public key with the same CKA_ID.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doListCertificates(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCertificatesCmd)
+
+	listCertificatesCmd.Flags().StringVar(&label, "label", "", "Show certificates with this label only")
+	listCertificatesCmd.Flags().StringVar(&keyid, "keyid", "", "Show certificates with this key id only")
+}
+
+func doListCertificates(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	certs, err := p11Token.ListCertificates(labelToUse, keyIdToUse)
+	handleError(err)
+
+	printResult(certs, func() {
+		for i, c := range certs {
+			fmt.Printf("[Certificate %d]\n", i+1)
+			printWithLabel("Label", c.Label)
+			printWithLabel("ID", fmt.Sprintf("%x", []byte(c.ID)))
+			printWithLabel("Subject", c.Subject)
+			printWithLabel("Issuer", c.Issuer)
+			printWithLabel("Serial", c.SerialNumber)
+			printWithLabel("Not Before", c.NotBefore.Format(time.RFC3339))
+			printWithLabel("Not After", c.NotAfter.Format(time.RFC3339))
+			printWithLabel("Key Match", fmt.Sprint(c.KeyMatch))
+			fmt.Println()
+		}
+	})
+}
+
+func printWithLabel(label, value string) {
+	fmt.Printf("  %-12s %s\n", label+":", value)
+}
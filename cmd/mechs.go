@@ -16,7 +16,6 @@
 package cmd
 
 import (
-	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/spf13/cobra"
 )
 
@@ -32,9 +31,17 @@ func init() {
 }
 
 func doMechs(cmd *cobra.Command, args []string) {
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 
 	defer func() { _ = p11Token.Finalise() }()
-	handleError(p11Token.PrintMechanisms())
+
+	if outputFormat == outputText {
+		handleError(p11Token.PrintMechanisms())
+		return
+	}
+
+	mechs, err := p11Token.ListMechanisms()
+	handleError(err)
+	printResult(mechs, nil)
 }
@@ -0,0 +1,92 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+)
+
+// randomCmd represents the random command
+var randomCmd = &cobra.Command{
+	Use:   "random",
+	Short: "Generate random bytes with the token's random number generator",
+	Long: `Writes random bytes from the token's random number generator, as hex, base64 or raw bytes, to a file or stdout.
+Additional seed material can be mixed in first with --seed, if the token supports it.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doRandom(cmd)
+	},
+}
+
+var randomBytes int
+var randomFormat string
+var seed string
+
+func init() {
+	rootCmd.AddCommand(randomCmd)
+
+	randomCmd.Flags().IntVar(&randomBytes, "bytes", 32, "Number of random bytes")
+	randomCmd.Flags().StringVar(&randomFormat, "format", "hex", "Format: hex, base64 or raw")
+	randomCmd.Flags().StringVar(&seed, "seed", "", "Hex encoded seed to mix into the token's generator first")
+	randomCmd.Flags().StringVar(&outFile, "out", "", "File to write to, instead of stdout")
+}
+
+func doRandom(cmd *cobra.Command) {
+
+	var encode func([]byte) string
+	switch randomFormat {
+	case "hex":
+		encode = hex.EncodeToString
+	case "base64", "raw":
+		encode = base64.StdEncoding.EncodeToString
+	default:
+		handleError(fmt.Errorf("unknown format '%s'", randomFormat))
+	}
+
+	var seedBytes []byte
+	if cmd.Flags().Changed("seed") {
+		var err error
+		seedBytes, err = hexutil.Decode(seed)
+		handleError(err)
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	if seedBytes != nil {
+		handleError(p11Token.SeedRandom(seedBytes))
+	}
+
+	random, err := p11Token.GenerateRandom(randomBytes)
+	handleError(err)
+
+	data := random
+	if randomFormat != "raw" {
+		data = []byte(encode(random) + "\n")
+	}
+
+	writeOutput(data, randomResult{Random: encode(random)})
+}
+
+// randomResult is the result of the random command, hex or base64 encoded.
+type randomResult struct {
+	Random string `json:"random" yaml:"random"`
+}
@@ -16,11 +16,14 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"strings"
 	"syscall"
 
+	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/spf13/cobra"
 	"golang.org/x/crypto/ssh/terminal"
 )
@@ -28,6 +31,22 @@ import (
 var p11Lib string
 var p11TokenLabel string
 var p11Pin string
+var p11TokenSerial string
+var p11URIString string
+
+var pinFile string
+var pinEnv string
+var pinFD int
+var pinCommand string
+
+// pinFlags are the mutually exclusive flags giving the PIN
+var pinFlags = []string{"pin", "pin-file", "pin-env", "pin-fd", "pin-command"}
+
+// pinSource is a file holding the PIN, from --uri or the config
+var pinSource string
+
+// p11URI is the parsed --uri, or nil if not given
+var p11URI *p11.URI
 
 var cfgFile string
 
@@ -36,6 +55,15 @@ var rootCmd = &cobra.Command{
 	Use:   "edge-identity",
 	Short: "DIMO Utility for PKCS#11 token based identity",
 	Long:  ``,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		if err := validateOutput(); err != nil {
+			return err
+		}
+		if err := applyURI(cmd); err != nil {
+			return err
+		}
+		return applyConfig(cmd)
+	},
 }
 
 // Execute adds all child commands to the root command and sets flags appropriately.
@@ -48,31 +76,128 @@ func Execute() {
 }
 
 func init() {
-	rootCmd.PersistentFlags().StringVar(&p11Lib, "lib", "", "Path to PKCS#11 library [required]")
-	rootCmd.PersistentFlags().StringVar(&p11TokenLabel, "token", "", "Token label [required]")
+	rootCmd.PersistentFlags().StringVar(&p11Lib, "lib", "", "Path to PKCS#11 library [required unless in --uri or config]")
+	rootCmd.PersistentFlags().StringVar(&p11TokenLabel, "token", "", "Token label [required unless in --uri or config]")
 	rootCmd.PersistentFlags().StringVar(&p11Pin, "pin", "", "Token user PIN (insecure). To avoid "+
 		"leaving PINs in your command history, omit this flag and enter the PIN when prompted.")
-	rootCmd.MarkPersistentFlagRequired("lib")
-	rootCmd.MarkPersistentFlagRequired("token")
+	rootCmd.PersistentFlags().StringVar(&pinFile, "pin-file", "", "Read the token user PIN from the first line of "+
+		"this file")
+	rootCmd.PersistentFlags().StringVar(&pinEnv, "pin-env", "", "Read the token user PIN from this environment "+
+		"variable")
+	rootCmd.PersistentFlags().IntVar(&pinFD, "pin-fd", 0, "Read the token user PIN from the first line of this "+
+		"open file descriptor")
+	rootCmd.PersistentFlags().StringVar(&pinCommand, "pin-command", "", "Run this shell command and read the "+
+		"token user PIN from the first line of its output")
+	rootCmd.MarkFlagsMutuallyExclusive(pinFlags...)
+	rootCmd.PersistentFlags().StringVar(&p11TokenSerial, "serial", "", "Token serial number, to choose between "+
+		"tokens with the same label")
+	rootCmd.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default is "+
+		strings.Join(configPaths(), " or ")+")")
+	rootCmd.PersistentFlags().StringVar(&p11URIString, "uri", "", "PKCS#11 URI (RFC 7512) giving any of the "+
+		"library, token, key and PIN, e.g. 'pkcs11:token=dimo;object=clitest?module-path=/usr/lib/softhsm/libsofthsm2.so'. "+
+		"Flags take precedence over the URI.")
 }
 
-// getPIN returns the token user PIN, reading it from the arguments (if supplied) or prompting the user to enter it
-// at the terminal.
-func getPIN(cmd *cobra.Command) string {
-	if cmd.Flags().Changed("pin") {
-		return p11Pin
+// applyURI parses --uri and uses its attributes for the --lib, --token, --serial, --pin, --label and --keyid flags
+// which were not given. The manufacturer and model attributes are used by openToken, and the type attribute by list.
+func applyURI(cmd *cobra.Command) error {
+	if !cmd.Flags().Changed("uri") {
+		return nil
+	}
+
+	var err error
+	p11URI, err = p11.ParseURI(p11URIString)
+	if err != nil {
+		return err
+	}
+
+	pinSource = p11URI.PINSource
+
+	for flag, value := range map[string]string{
+		"lib":    p11URI.ModulePath,
+		"token":  p11URI.Token,
+		"serial": p11URI.Serial,
+		"pin":    p11URI.PINValue,
+		"label":  p11URI.Object,
+		"keyid":  p11URI.ID,
+	} {
+		if value == "" || cmd.Flags().Lookup(flag) == nil || cmd.Flags().Changed(flag) {
+			continue
+		}
+		if flag == "pin" && pinFlagGiven(cmd) {
+			continue
+		}
+
+		if err := cmd.Flags().Set(flag, value); err != nil {
+			return err
+		}
 	}
 
-	fmt.Print("Token user PIN: ")
-	pinBytes, err := terminal.ReadPassword(int(syscall.Stdin))
-	handleError(err)
+	return nil
+}
 
-	return string(pinBytes)
+// openToken logs in to the token given by --lib, --token and --serial, or by --uri.
+func openToken(cmd *cobra.Command) (p11.Token, error) {
+	var spec p11.TokenSpec
+	if p11URI != nil {
+		spec = p11URI.TokenSpec()
+	}
+	// applyURI has copied the token and serial of the URI to the flags, unless they were given
+	spec.Label, spec.Serial = p11TokenLabel, p11TokenSerial
+
+	if p11Lib == "" {
+		return nil, errors.New("--lib, or a module-path in --uri, is required")
+	}
+	if spec == (p11.TokenSpec{}) {
+		return nil, errors.New("--token or --serial, or a token in --uri, is required")
+	}
+
+	return p11.NewTokenWithSpec(p11Lib, spec, getPIN(cmd))
+}
+
+// getPIN returns the source of the token user PIN: the --pin, --pin-file, --pin-env, --pin-fd or --pin-command flag
+// (if supplied), the pin-source file of --uri or the config, or else a prompt for the user to enter it at the
+// terminal.
+func getPIN(cmd *cobra.Command) p11.PINSource {
+	flags := cmd.Flags()
+	switch {
+	case flags.Changed("pin"):
+		return p11.PINFromBytes([]byte(p11Pin))
+	case flags.Changed("pin-file"):
+		return p11.PINFromFile(pinFile)
+	case flags.Changed("pin-env"):
+		return p11.PINFromEnv(pinEnv)
+	case flags.Changed("pin-fd"):
+		return p11.PINFromFD(pinFD)
+	case flags.Changed("pin-command"):
+		return p11.PINFromCommand("/bin/sh", "-c", pinCommand)
+	case pinSource != "":
+		return p11.PINFromFile(strings.TrimPrefix(pinSource, "file:"))
+	default:
+		return p11.PINFunc(func() ([]byte, error) {
+			fmt.Print("Token user PIN: ")
+			defer fmt.Println()
+			return terminal.ReadPassword(int(syscall.Stdin))
+		})
+	}
+}
+
+// pinFlagGiven reports whether any of the PIN flags was given.
+func pinFlagGiven(cmd *cobra.Command) bool {
+	for _, flag := range pinFlags {
+		if cmd.Flags().Changed(flag) {
+			return true
+		}
+	}
+	return false
 }
 
-// handleError prints the error and exits, if err != nil
+// handleError prints the error and exits, if err != nil. For JSON and YAML output the error is also written to stdout.
 func handleError(err error) {
 	if err != nil {
+		if outputFormat != outputText {
+			printResult(errorResult{Error: err.Error()}, nil)
+		}
 		log.Printf("An error occurred: %s", err.Error())
 		os.Exit(1)
 	}
@@ -0,0 +1,179 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// selfSignCmd represents the selfSign command
+var selfSignCmd = &cobra.Command{
+	Use:   "selfSign",
+	Short: "Create a self-signed X.509 certificate for a key pair",
+	Long: `Creates a PEM encoded self-signed X.509 certificate for a secp256k1, P-256, P-384, Ed25519 or RSA key pair
+on the token. The subject and SANs are taken from the command line and/or a YAML template, as for csr. With --store
+the certificate is also imported onto the token with the label and CKA_ID of the key pair.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSelfSign(cmd)
+	},
+}
+
+// keyUsages are the names accepted by --key-usage.
+var keyUsages = map[string]x509.KeyUsage{
+	"digitalSignature":  x509.KeyUsageDigitalSignature,
+	"contentCommitment": x509.KeyUsageContentCommitment,
+	"keyEncipherment":   x509.KeyUsageKeyEncipherment,
+	"dataEncipherment":  x509.KeyUsageDataEncipherment,
+	"keyAgreement":      x509.KeyUsageKeyAgreement,
+	"certSign":          x509.KeyUsageCertSign,
+	"crlSign":           x509.KeyUsageCRLSign,
+}
+
+// extKeyUsages are the names accepted by --ext-key-usage.
+var extKeyUsages = map[string]x509.ExtKeyUsage{
+	"serverAuth":      x509.ExtKeyUsageServerAuth,
+	"clientAuth":      x509.ExtKeyUsageClientAuth,
+	"codeSigning":     x509.ExtKeyUsageCodeSigning,
+	"emailProtection": x509.ExtKeyUsageEmailProtection,
+	"timeStamping":    x509.ExtKeyUsageTimeStamping,
+	"ocspSigning":     x509.ExtKeyUsageOCSPSigning,
+}
+
+var validityDays int
+var keyUsageNames []string
+var extKeyUsageNames []string
+var isCA bool
+var storeCertificate bool
+var certOut string
+
+func init() {
+	rootCmd.AddCommand(selfSignCmd)
+
+	selfSignCmd.Flags().StringVar(&label, "label", "", "Label of the key pair")
+	selfSignCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the key pair")
+	selfSignCmd.Flags().IntVar(&validityDays, "days", 365, "Number of days the certificate is valid for")
+	selfSignCmd.Flags().StringSliceVar(&keyUsageNames, "key-usage", []string{"digitalSignature"},
+		"Key usages: digitalSignature, contentCommitment, keyEncipherment, dataEncipherment, keyAgreement, "+
+			"certSign, crlSign")
+	selfSignCmd.Flags().StringSliceVar(&extKeyUsageNames, "ext-key-usage", []string{"serverAuth", "clientAuth"},
+		"Extended key usages: serverAuth, clientAuth, codeSigning, emailProtection, timeStamping, ocspSigning")
+	selfSignCmd.Flags().BoolVar(&isCA, "ca", false, "Mark the certificate as a CA")
+	selfSignCmd.Flags().BoolVar(&storeCertificate, "store", false, "Store the certificate on the token")
+	selfSignCmd.Flags().StringVar(&certOut, "out", "", "File to write the certificate to, instead of stdout")
+	addTemplateFlags(selfSignCmd)
+}
+
+func doSelfSign(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	if validityDays <= 0 {
+		handleError(errors.New("--days must be positive"))
+	}
+
+	t, err := loadCertTemplate()
+	handleError(err)
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	handleError(err)
+
+	notBefore := time.Now().Add(-5 * time.Minute)
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		NotBefore:             notBefore,
+		NotAfter:              notBefore.AddDate(0, 0, validityDays),
+		DNSNames:              t.DNSNames,
+		EmailAddresses:        t.EmailAddresses,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+	template.Subject, err = t.subjectName()
+	handleError(err)
+	template.IPAddresses, err = t.ips()
+	handleError(err)
+	template.URIs, err = t.parsedURIs()
+	handleError(err)
+	template.ExtraExtensions, err = t.extensions()
+	handleError(err)
+
+	for _, name := range keyUsageNames {
+		usage, ok := keyUsages[name]
+		if !ok {
+			handleError(fmt.Errorf("unknown key usage '%s'", name))
+		}
+		template.KeyUsage |= usage
+	}
+	for _, name := range extKeyUsageNames {
+		usage, ok := extKeyUsages[name]
+		if !ok {
+			handleError(fmt.Errorf("unknown extended key usage '%s'", name))
+		}
+		template.ExtKeyUsage = append(template.ExtKeyUsage, usage)
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	signer, err := p11Token.Signer(labelToUse, keyIdToUse)
+	handleError(err)
+
+	der, err := p11.CreateSelfSignedCertificate(template, signer)
+	handleError(err)
+
+	if storeCertificate {
+		_, err = p11Token.ImportCertificates(labelToUse, keyIdToUse, [][]byte{der})
+		handleError(err)
+	}
+
+	encoded := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if certOut != "" {
+		handleError(os.WriteFile(certOut, encoded, 0644))
+	}
+
+	printResult(certificateResult{Format: "pem", Certificate: string(encoded)}, func() {
+		if certOut == "" {
+			os.Stdout.Write(encoded)
+		}
+		if storeCertificate {
+			log.Printf("Stored certificate with serial %s", strings.ToUpper(serial.Text(16)))
+		}
+	})
+}
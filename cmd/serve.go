@@ -0,0 +1,180 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/DIMO-Network/edge-identity/server"
+	"github.com/DIMO-Network/edge-identity/wallet"
+	"github.com/spf13/cobra"
+)
+
+// serveCmd represents the serve command
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Serve a JSON-RPC signing endpoint",
+	Long: `Logs in to the token once and serves JSON-RPC 2.0 over HTTP on a unix socket or a localhost TCP port, or
+as a stream on an IPC socket, so that other processes can sign without loading the PKCS#11 library or knowing the PIN.
+
+Every secp256k1 key pair on the token is an account. The supported methods are eth_accounts, eth_sign,
+personal_sign, eth_signTransaction and eth_signTypedData_v4, along with account_list, account_signTransaction,
+account_signData, account_signTypedData and account_version from Clef's external API. With --ipc the daemon can be
+used as the --signer of geth and other tools which support Clef.
+
+HTTP requests must have a Content-Type of application/json and a loopback Host, or one given by --vhosts. Browser
+requests, which carry an Origin, are rejected unless the origin is given by --origins. With --auth-token-file every
+HTTP request must also carry the token in the file as "Authorization: Bearer <token>"; this is required if "*" is
+given to --vhosts or --origins.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doServe(cmd)
+	},
+}
+
+var socketPath string
+var ipcPath string
+var listenAddr string
+var vhosts []string
+var origins []string
+var authTokenFile string
+
+func init() {
+	rootCmd.AddCommand(serveCmd)
+
+	serveCmd.Flags().StringVar(&socketPath, "socket", "", "Serve HTTP on this unix socket")
+	serveCmd.Flags().StringVar(&ipcPath, "ipc", "", "Serve IPC, as used by Clef, on this unix socket")
+	serveCmd.Flags().StringVar(&listenAddr, "addr", "127.0.0.1:8545", "Serve on this localhost TCP address")
+	serveCmd.Flags().Int64Var(&chainID, "chainid", 0, "Chain ID for transactions which do not specify one")
+	serveCmd.Flags().StringSliceVar(&vhosts, "vhosts", nil, "Host names accepted besides loopback ones, or * for any")
+	serveCmd.Flags().StringSliceVar(&origins, "origins", nil, "Browser origins accepted, or * for any")
+	serveCmd.Flags().StringVar(&authTokenFile, "auth-token-file", "", "File holding a bearer token required over HTTP")
+
+	serveCmd.MarkFlagsMutuallyExclusive("socket", "ipc", "addr")
+}
+
+func doServe(cmd *cobra.Command) {
+	var chainIDToUse *big.Int
+	if cmd.Flags().Changed("chainid") {
+		chainIDToUse = big.NewInt(chainID)
+	}
+
+	opts, err := httpOptions()
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	w, err := wallet.NewWallet(p11Token, p11TokenLabel)
+	handleError(err)
+
+	listener, err := listen(cmd)
+	handleError(err)
+
+	rpcServer := server.New(w, chainIDToUse, opts)
+	httpServer := &http.Server{Handler: rpcServer}
+
+	// Close the listener on shutdown, so the token is finalised and any socket removed
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-sigs
+		httpServer.Close()
+		listener.Close()
+	}()
+
+	log.Printf("Serving %d accounts on %s", len(w.Accounts()), listener.Addr())
+	if cmd.Flags().Changed("ipc") {
+		handleError(rpcServer.ServeIPC(listener))
+		return
+	}
+
+	err = httpServer.Serve(listener)
+	if !errors.Is(err, http.ErrServerClosed) {
+		handleError(err)
+	}
+}
+
+// httpOptions returns the HTTP restrictions given by --vhosts, --origins and --auth-token-file.
+func httpOptions() (server.HTTPOptions, error) {
+	opts := server.HTTPOptions{VHosts: vhosts, Origins: origins}
+
+	if authTokenFile != "" {
+		token, err := os.ReadFile(authTokenFile)
+		if err != nil {
+			return opts, err
+		}
+		opts.Token = strings.TrimSpace(string(token))
+		if opts.Token == "" {
+			return opts, errors.New("--auth-token-file is empty")
+		}
+	}
+
+	wildcard := false
+	for _, entry := range append(append([]string{}, vhosts...), origins...) {
+		wildcard = wildcard || entry == "*"
+	}
+	if opts.Token == "" && wildcard {
+		return opts, errors.New("--auth-token-file is required when any host or origin is allowed")
+	}
+
+	return opts, nil
+}
+
+// listen opens the unix socket given by --socket or --ipc, readable only by the current user, or else the TCP
+// address given by --addr, which must be a loopback address.
+func listen(cmd *cobra.Command) (net.Listener, error) {
+	path := socketPath
+	if cmd.Flags().Changed("ipc") {
+		path = ipcPath
+	}
+
+	if path != "" {
+		// Create the socket without group or other access, rather than narrowing it after another user could
+		// already have connected
+		mask := syscall.Umask(0077)
+		listener, err := net.Listen("unix", path)
+		syscall.Umask(mask)
+		if err != nil {
+			return nil, err
+		}
+
+		if err := os.Chmod(path, 0600); err != nil {
+			listener.Close()
+			return nil, fmt.Errorf("failed to restrict access to %s: %w", path, err)
+		}
+		return listener, nil
+	}
+
+	host, _, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, errors.New("--addr must be a loopback address")
+	}
+
+	return net.Listen("tcp", listenAddr)
+}
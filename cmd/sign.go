@@ -16,11 +16,11 @@
 package cmd
 
 import (
+	"errors"
 	"log"
 
 	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/cobra"
 )
 
@@ -28,6 +28,9 @@ import (
 var signCmd = &cobra.Command{
 	Use:   "sign",
 	Short: "Sign payload message",
+	Long: `Signs a message or hash with an EC key. secp256k1 keys give a 65-byte R||S||V Ethereum signature, P-256
+and P-384 keys give R||S and Ed25519 keys give a 64-byte EdDSA signature. Messages are signed with RSA keys by
+giving a --scheme.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		doSign(cmd)
 	},
@@ -39,10 +42,19 @@ func init() {
 	signCmd.Flags().StringVar(&label, "label", "", "Use token with this label")
 	signCmd.Flags().StringVar(&keyid, "keyid", "", "Use token with this keyid")
 	signCmd.Flags().StringVar(&hash, "hash", "", "Hash to sign")
-	signCmd.Flags().StringVar(&message, "message", "", "Message to sign")
+	signCmd.Flags().StringVar(&message, "message", "", "Message to sign, hashed with Keccak-256 for secp256k1 "+
+		"keys, SHA-256 for P-256 and SHA-384 for P-384. Ed25519 keys sign the message itself.")
+	signCmd.Flags().BoolVar(&personal, "personal", false,
+		"Sign the message with the EIP-191 prefix, as personal_sign and eth_sign do")
+
+	signCmd.Flags().StringVar(&rsaScheme, "scheme", "", "Sign the message with an RSA key using this scheme: "+
+		"RS256, RS384, RS512, PS256, PS384 or PS512")
 
 	signCmd.MarkFlagRequired("label")
 	signCmd.MarkFlagsMutuallyExclusive("message", "hash")
+	signCmd.MarkFlagsMutuallyExclusive("personal", "hash")
+	signCmd.MarkFlagsMutuallyExclusive("scheme", "hash")
+	signCmd.MarkFlagsMutuallyExclusive("scheme", "personal")
 }
 
 func doSign(cmd *cobra.Command) {
@@ -56,20 +68,49 @@ func doSign(cmd *cobra.Command) {
 	if cmd.Flags().Changed("keyid") {
 		keyIdToUse = keyid
 	}
-	var hashToSign []byte
-	if cmd.Flags().Changed("message") {
-		hashToSign = crypto.Keccak256([]byte(message))
+	if personal && !cmd.Flags().Changed("message") {
+		handleError(errors.New("--personal requires --message"))
+	}
+
+	var scheme p11.RSAScheme
+	if cmd.Flags().Changed("scheme") {
+		if !cmd.Flags().Changed("message") {
+			handleError(errors.New("--scheme requires --message"))
+		}
+		scheme, err = p11.ParseRSAScheme(rsaScheme)
+		handleError(err)
 	}
 
+	var hashToSign []byte
 	if cmd.Flags().Changed("hash") {
 		hashToSign, err = hexutil.Decode(hash)
 		handleError(err)
 	}
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 	defer p11Token.Finalise()
 
-	result, err := p11Token.Sign(labelToUse, keyIdToUse, hashToSign)
+	var result []byte
+	if personal {
+		result, err = p11Token.SignPersonal(labelToUse, keyIdToUse, []byte(message))
+	} else if scheme != "" {
+		result, err = p11Token.SignRSA(labelToUse, keyIdToUse, scheme, []byte(message))
+	} else if cmd.Flags().Changed("message") {
+		result, err = p11Token.SignMessage(labelToUse, keyIdToUse, []byte(message))
+	} else {
+		result, err = p11Token.Sign(labelToUse, keyIdToUse, hashToSign)
+	}
 	handleError(err)
-	log.Printf("Signature %s", hexutil.Encode(result))
+
+	printResult(signatureResult{Signature: hexutil.Encode(result)}, func() {
+		log.Printf("Signature %s", hexutil.Encode(result))
+	})
+}
+
+// rsaScheme is the --scheme for RSA signatures, shared by sign and verify.
+var rsaScheme string
+
+// signatureResult is the result of the signing commands.
+type signatureResult struct {
+	Signature string `json:"signature" yaml:"signature"`
 }
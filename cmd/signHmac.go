@@ -0,0 +1,95 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+)
+
+// signHmacCmd represents the signHmac command
+var signHmacCmd = &cobra.Command{
+	Use:   "signHmac",
+	Short: "Compute the HMAC of a message with a generic secret key",
+	Long: `Computes the HMAC-SHA-256 (HS256) or HMAC-SHA-512 (HS512) of a message, or a file, on the token with a generic
+secret key, as created by generateKeyPair or import with --keytype GENERIC.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSignHmac(cmd)
+	},
+}
+
+var hmacAlgorithm string
+
+func init() {
+	rootCmd.AddCommand(signHmacCmd)
+
+	signHmacCmd.Flags().StringVar(&label, "label", "", "Label of the generic secret key")
+	signHmacCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the generic secret key")
+	signHmacCmd.Flags().StringVar(&message, "message", "", "Message to authenticate")
+	signHmacCmd.Flags().StringVar(&inFile, "in", "", "File to authenticate, or - for stdin")
+	signHmacCmd.Flags().StringVar(&hmacAlgorithm, "alg", string(p11.HS256), "Algorithm: HS256 or HS512")
+
+	signHmacCmd.MarkFlagsMutuallyExclusive("message", "in")
+}
+
+func doSignHmac(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	alg, err := p11.ParseHMACAlgorithm(hmacAlgorithm)
+	handleError(err)
+
+	data, err := hmacMessage(cmd)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	mac, err := p11Token.SignHMAC(labelToUse, keyIdToUse, alg, data)
+	handleError(err)
+
+	printResult(signatureResult{Signature: hexutil.Encode(mac)}, func() {
+		log.Printf("HMAC %s", hexutil.Encode(mac))
+	})
+}
+
+// hmacMessage returns the --message, or the contents of --in, for signHmac and verifyHmac.
+func hmacMessage(cmd *cobra.Command) ([]byte, error) {
+	if cmd.Flags().Changed("in") {
+		return readInput(inFile)
+	}
+	if !cmd.Flags().Changed("message") {
+		return nil, errors.New("must specify --message or --in")
+	}
+	return []byte(message), nil
+}
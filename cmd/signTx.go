@@ -0,0 +1,148 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"math/big"
+	"os"
+
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/spf13/cobra"
+)
+
+// signTxCmd represents the signTx command
+var signTxCmd = &cobra.Command{
+	Use:   "signTx",
+	Short: "Sign an Ethereum transaction",
+	Long: `Signs a legacy, EIP-2930 or EIP-1559 transaction and prints the signed raw transaction, ready for
+eth_sendRawTransaction.
+
+The unsigned transaction is given either as a JSON file in the eth_signTransaction format (to, gas, gasPrice or
+maxFeePerGas and maxPriorityFeePerGas, value, nonce, input, accessList, chainId) or as the hex encoding of the
+transaction with zero signature values. An EIP-1559 transaction is built when maxFeePerGas is set, and an EIP-2930
+transaction when only accessList is set.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSignTx(cmd)
+	},
+}
+
+var txFile string
+var rawTx string
+var chainID int64
+
+func init() {
+	rootCmd.AddCommand(signTxCmd)
+
+	signTxCmd.Flags().StringVar(&label, "label", "", "Use token with this label")
+	signTxCmd.Flags().StringVar(&keyid, "keyid", "", "Use token with this keyid")
+	signTxCmd.Flags().StringVar(&txFile, "file", "", "Path to unsigned transaction JSON")
+	signTxCmd.Flags().StringVar(&rawTx, "tx", "", "Hex encoded unsigned transaction")
+	signTxCmd.Flags().Int64Var(&chainID, "chainid", 0,
+		"Chain ID to sign for (defaults to the chain ID in the transaction, required for legacy transactions)")
+
+	signTxCmd.MarkFlagRequired("label")
+	signTxCmd.MarkFlagsMutuallyExclusive("file", "tx")
+}
+
+func doSignTx(cmd *cobra.Command) {
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	tx, txChainID, err := readUnsignedTx(cmd)
+	handleError(err)
+
+	if cmd.Flags().Changed("chainid") {
+		txChainID = big.NewInt(chainID)
+	}
+	if txChainID == nil {
+		handleError(errors.New("--chainid is required for legacy transactions"))
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	signedTx, err := p11Token.SignTx(labelToUse, keyIdToUse, tx, txChainID)
+	handleError(err)
+
+	raw, err := signedTx.MarshalBinary()
+	handleError(err)
+
+	printResult(signTxResult{Hash: signedTx.Hash().Hex(), Raw: hexutil.Encode(raw)}, func() {
+		log.Printf("Transaction hash %s", signedTx.Hash())
+		log.Printf("Signed transaction %s", hexutil.Encode(raw))
+	})
+}
+
+// signTxResult is the result of the signTx command.
+type signTxResult struct {
+	Hash string `json:"hash" yaml:"hash"`
+	Raw  string `json:"raw" yaml:"raw"`
+}
+
+// readUnsignedTx returns the transaction given by --file or --tx, along with the chain ID it carries (nil if none).
+func readUnsignedTx(cmd *cobra.Command) (*types.Transaction, *big.Int, error) {
+	switch {
+	case cmd.Flags().Changed("file"):
+		data, err := os.ReadFile(txFile)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		var args apitypes.SendTxArgs
+		if err := json.Unmarshal(data, &args); err != nil {
+			return nil, nil, err
+		}
+
+		var txChainID *big.Int
+		if args.ChainID != nil {
+			txChainID = args.ChainID.ToInt()
+		}
+		return args.ToTransaction(), txChainID, nil
+
+	case cmd.Flags().Changed("tx"):
+		data, err := hexutil.Decode(rawTx)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tx := new(types.Transaction)
+		if err := tx.UnmarshalBinary(data); err != nil {
+			return nil, nil, err
+		}
+
+		// Legacy transactions only carry a chain ID once signed
+		if tx.Type() == types.LegacyTxType {
+			return tx, nil, nil
+		}
+		return tx, tx.ChainId(), nil
+
+	default:
+		return nil, nil, errors.New("one of --file or --tx is required")
+	}
+}
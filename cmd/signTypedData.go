@@ -0,0 +1,78 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"log"
+	"os"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+)
+
+// signTypedDataCmd represents the signTypedData command
+var signTypedDataCmd = &cobra.Command{
+	Use:   "signTypedData",
+	Short: "Sign EIP-712 typed data",
+	Long: `Signs an EIP-712 JSON document containing the domain, types, primaryType and message fields, as
+accepted by eth_signTypedData_v4. The types must include the EIP712Domain definition.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doSignTypedData(cmd)
+	},
+}
+
+var typedDataFile string
+
+func init() {
+	rootCmd.AddCommand(signTypedDataCmd)
+
+	signTypedDataCmd.Flags().StringVar(&label, "label", "", "Use token with this label")
+	signTypedDataCmd.Flags().StringVar(&keyid, "keyid", "", "Use token with this keyid")
+	signTypedDataCmd.Flags().StringVar(&typedDataFile, "file", "", "Path to EIP-712 JSON document [required]")
+
+	signTypedDataCmd.MarkFlagRequired("label")
+	signTypedDataCmd.MarkFlagRequired("file")
+}
+
+func doSignTypedData(cmd *cobra.Command) {
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	data, err := os.ReadFile(typedDataFile)
+	handleError(err)
+
+	typedData, err := p11.ParseTypedData(data)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	result, err := p11Token.SignTypedData(labelToUse, keyIdToUse, typedData)
+	handleError(err)
+
+	printResult(signatureResult{Signature: hexutil.Encode(result)}, func() {
+		log.Printf("Signature %s", hexutil.Encode(result))
+	})
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// unwrapKeyCmd represents the unwrapKey command
+var unwrapKeyCmd = &cobra.Command{
+	Use:   "unwrapKey",
+	Short: "Import a key written by wrapKey",
+	Long: `Decrypts a key written by wrapKey with the unwrapping key, which is the same AES key for aes-key-wrap-pad or
+the RSA private key for rsa-oaep, and stores it on the token. The key is non-extractable unless --extractable is
+given. Unwrapped EC, Ed25519 and RSA private keys get a public key object too. For EC and Ed25519 keys the public key
+is not part of the wrapped key, so --public-key must give it, as written by exportPublicKey on the source token; it is
+checked against the unwrapped key before being stored.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doUnwrapKey(cmd)
+	},
+}
+
+var unwrapKeyType string
+var unwrapPublicKey string
+
+func init() {
+	rootCmd.AddCommand(unwrapKeyCmd)
+
+	unwrapKeyCmd.Flags().StringVar(&label, "label", "", "Label for the unwrapped key [required]")
+	unwrapKeyCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId for the unwrapped key (default is the label)")
+	unwrapKeyCmd.Flags().StringVar(&unwrapKeyType, "keytype", "AES",
+		"Type of the wrapped key: AES, GENERIC, EC, ED25519 or RSA")
+	unwrapKeyCmd.Flags().StringVar(&wrappingLabel, "wrapping-label", "", "Label of the unwrapping key")
+	unwrapKeyCmd.Flags().StringVar(&wrappingKeyid, "wrapping-keyid", "", "KeyId of the unwrapping key")
+	unwrapKeyCmd.Flags().StringVar(&wrapMechanism, "mechanism", string(p11.WrapAESKeyWrapPad),
+		"Mechanism: aes-key-wrap-pad or rsa-oaep")
+	unwrapKeyCmd.Flags().StringVar(&inFile, "in", "-", "File holding the wrapped key, or - for stdin")
+	unwrapKeyCmd.Flags().StringVar(&unwrapPublicKey, "public-key", "",
+		"File holding the public key of a wrapped EC or Ed25519 key (PEM, DER, JWK or hex)")
+	unwrapKeyCmd.Flags().BoolVar(&extractable, "extractable", false, "Allow the unwrapped key to be wrapped again")
+
+	unwrapKeyCmd.MarkFlagRequired("label")
+}
+
+func doUnwrapKey(cmd *cobra.Command) {
+
+	if wrappingLabel == "" && wrappingKeyid == "" {
+		handleError(errors.New("must specify --wrapping-label and/or --wrapping-keyid"))
+	}
+
+	mechanism, err := p11.ParseWrapMechanism(wrapMechanism)
+	handleError(err)
+
+	wrapped, err := readInput(inFile)
+	handleError(err)
+
+	var publicKey []byte
+	if unwrapPublicKey != "" {
+		publicKey, err = os.ReadFile(unwrapPublicKey)
+		handleError(err)
+	}
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	handleError(p11Token.UnwrapKey(wrappingLabel, wrappingKeyid, mechanism, wrapped, label, keyid,
+		strings.ToUpper(unwrapKeyType), publicKey, extractable))
+
+	printResult(keyResult{Label: label, KeyID: keyid}, func() {
+		log.Println("Key unwrapped successfully")
+	})
+}
@@ -16,9 +16,11 @@
 package cmd
 
 import (
+	"errors"
+	"log"
+
 	"github.com/DIMO-Network/edge-identity/p11"
 	"github.com/ethereum/go-ethereum/common/hexutil"
-	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/spf13/cobra"
 )
 
@@ -40,10 +42,18 @@ func init() {
 	verifyCmd.Flags().StringVar(&keyid, "keyid", "", "Use token with this keyid")
 	verifyCmd.Flags().StringVar(&message, "message", "", "Original message")
 	verifyCmd.Flags().StringVar(&hash, "hash", "", "Original hash")
+	verifyCmd.Flags().BoolVar(&personal, "personal", false,
+		"Verify an EIP-191 signature over the message, as produced by personal_sign and eth_sign")
+
+	verifyCmd.Flags().StringVar(&rsaScheme, "scheme", "", "Verify an RSA signature over the message made with "+
+		"this scheme: RS256, RS384, RS512, PS256, PS384 or PS512")
 
 	verifyCmd.MarkFlagRequired("label")
 	verifyCmd.MarkFlagRequired("signature")
 	verifyCmd.MarkFlagsMutuallyExclusive("message", "hash")
+	verifyCmd.MarkFlagsMutuallyExclusive("personal", "hash")
+	verifyCmd.MarkFlagsMutuallyExclusive("scheme", "hash")
+	verifyCmd.MarkFlagsMutuallyExclusive("scheme", "personal")
 }
 
 func doVerify(cmd *cobra.Command) {
@@ -64,22 +74,48 @@ func doVerify(cmd *cobra.Command) {
 		signatureTouse = &signature
 	}
 
-	var hashToVerify []byte
-	if cmd.Flags().Changed("message") {
-		hashToVerify = crypto.Keccak256([]byte(message))
+	if personal && !cmd.Flags().Changed("message") {
+		handleError(errors.New("--personal requires --message"))
+	}
+
+	var scheme p11.RSAScheme
+	if cmd.Flags().Changed("scheme") {
+		if !cmd.Flags().Changed("message") {
+			handleError(errors.New("--scheme requires --message"))
+		}
+		scheme, err = p11.ParseRSAScheme(rsaScheme)
+		handleError(err)
 	}
 
+	var hashToVerify []byte
 	if cmd.Flags().Changed("hash") {
 		hashToVerify, err = hexutil.Decode(hash)
 		handleError(err)
 	}
-	p11Token, err := p11.NewToken(p11Lib, p11TokenLabel, getPIN(cmd))
+	p11Token, err := openToken(cmd)
 	handleError(err)
 	defer p11Token.Finalise()
 
 	sig, err := hexutil.Decode(*signatureTouse)
 	handleError(err)
 
-	err = p11Token.Verify(labelToUse, keyIdToUse, hashToVerify, sig)
+	if personal {
+		err = p11Token.VerifyPersonal(labelToUse, keyIdToUse, []byte(message), sig)
+	} else if scheme != "" {
+		err = p11Token.VerifyRSA(labelToUse, keyIdToUse, scheme, []byte(message), sig)
+	} else if cmd.Flags().Changed("message") {
+		err = p11Token.VerifyMessage(labelToUse, keyIdToUse, []byte(message), sig)
+	} else {
+		err = p11Token.Verify(labelToUse, keyIdToUse, hashToVerify, sig)
+	}
 	handleError(err)
+
+	printResult(verifyResult{Verified: true}, func() {
+		log.Println("Verified successfully")
+	})
+}
+
+// verifyResult is the result of the verify command. Failed verification is reported as an error.
+type verifyResult struct {
+	Verified bool `json:"verified" yaml:"verified"`
 }
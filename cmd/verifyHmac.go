@@ -0,0 +1,84 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"errors"
+	"log"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/spf13/cobra"
+)
+
+// verifyHmacCmd represents the verifyHmac command
+var verifyHmacCmd = &cobra.Command{
+	Use:   "verifyHmac",
+	Short: "Verify the HMAC of a message with a generic secret key",
+	Run: func(cmd *cobra.Command, args []string) {
+		doVerifyHmac(cmd)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(verifyHmacCmd)
+
+	verifyHmacCmd.Flags().StringVar(&label, "label", "", "Label of the generic secret key")
+	verifyHmacCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the generic secret key")
+	verifyHmacCmd.Flags().StringVar(&signature, "mac", "", "Hex encoded HMAC to verify [required]")
+	verifyHmacCmd.Flags().StringVar(&message, "message", "", "Original message")
+	verifyHmacCmd.Flags().StringVar(&inFile, "in", "", "Original file, or - for stdin")
+	verifyHmacCmd.Flags().StringVar(&hmacAlgorithm, "alg", string(p11.HS256), "Algorithm: HS256 or HS512")
+
+	verifyHmacCmd.MarkFlagRequired("mac")
+	verifyHmacCmd.MarkFlagsMutuallyExclusive("message", "in")
+}
+
+func doVerifyHmac(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	alg, err := p11.ParseHMACAlgorithm(hmacAlgorithm)
+	handleError(err)
+
+	mac, err := hexutil.Decode(signature)
+	handleError(err)
+
+	data, err := hmacMessage(cmd)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	handleError(p11Token.VerifyHMAC(labelToUse, keyIdToUse, alg, data, mac))
+
+	printResult(verifyResult{Verified: true}, func() {
+		log.Println("Verified successfully")
+	})
+}
@@ -0,0 +1,85 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package cmd
+
+import (
+	"encoding/base64"
+	"errors"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/spf13/cobra"
+)
+
+// wrapKeyCmd represents the wrapKey command
+var wrapKeyCmd = &cobra.Command{
+	Use:   "wrapKey",
+	Short: "Export an extractable key encrypted under a wrapping key",
+	Long: `Exports an extractable AES or private key, encrypted under a wrapping key, for backup or to move it to another
+token with unwrapKey. aes-key-wrap-pad wraps under an AES key; rsa-oaep wraps AES keys under an RSA public key, so only
+the holder of the private key can unwrap them.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		doWrapKey(cmd)
+	},
+}
+
+var wrappingLabel string
+var wrappingKeyid string
+var wrapMechanism string
+
+func init() {
+	rootCmd.AddCommand(wrapKeyCmd)
+
+	wrapKeyCmd.Flags().StringVar(&label, "label", "", "Label of the key to wrap")
+	wrapKeyCmd.Flags().StringVar(&keyid, "keyid", "", "KeyId of the key to wrap")
+	wrapKeyCmd.Flags().StringVar(&wrappingLabel, "wrapping-label", "", "Label of the wrapping key")
+	wrapKeyCmd.Flags().StringVar(&wrappingKeyid, "wrapping-keyid", "", "KeyId of the wrapping key")
+	wrapKeyCmd.Flags().StringVar(&wrapMechanism, "mechanism", string(p11.WrapAESKeyWrapPad),
+		"Mechanism: aes-key-wrap-pad or rsa-oaep")
+	wrapKeyCmd.Flags().StringVar(&outFile, "out", "", "File to write the wrapped key to, instead of stdout")
+}
+
+func doWrapKey(cmd *cobra.Command) {
+
+	var labelToUse string
+	if cmd.Flags().Changed("label") {
+		labelToUse = label
+	}
+
+	var keyIdToUse string
+	if cmd.Flags().Changed("keyid") {
+		keyIdToUse = keyid
+	}
+
+	if labelToUse == "" && keyIdToUse == "" {
+		handleError(errors.New("must specify --label and/or --keyid"))
+	}
+
+	if wrappingLabel == "" && wrappingKeyid == "" {
+		handleError(errors.New("must specify --wrapping-label and/or --wrapping-keyid"))
+	}
+
+	mechanism, err := p11.ParseWrapMechanism(wrapMechanism)
+	handleError(err)
+
+	p11Token, err := openToken(cmd)
+	handleError(err)
+	defer p11Token.Finalise()
+
+	wrapped, err := p11Token.WrapKey(wrappingLabel, wrappingKeyid, mechanism, labelToUse, keyIdToUse)
+	handleError(err)
+
+	writeOutput(wrapped, cipherResult{Data: base64.StdEncoding.EncodeToString(wrapped)})
+}
@@ -34,37 +34,20 @@ type AttributeInfo struct {
 	name      string
 }
 
-func printObject(ctx TokenCtx, session pkcs11.SessionHandle, object pkcs11.ObjectHandle, objNum int) error {
+// ObjectAttributes maps the names of an object's attributes, such as CKA_LABEL, to their printable values. Sensitive
+// attributes have the value "<sensitive>" and attributes the object does not have are left out.
+type ObjectAttributes map[string]string
+
+func printObject(info ObjectInfo, objNum int) {
 	fmt.Printf("[Object %d]\n", objNum)
 
 	for _, attr := range attributeInfo {
-
-		template := []*pkcs11.Attribute{{attr.aType, nil}}
-		template, err := ctx.GetAttributeValue(session, object, template)
-
-		if p11error, ok := err.(pkcs11.Error); ok {
-			switch p11error {
-			case pkcs11.CKR_ATTRIBUTE_SENSITIVE:
-				printWithLabel(attr.name, "<sensitive>")
-				continue
-			case pkcs11.CKR_ATTRIBUTE_TYPE_INVALID:
-				continue
-			default:
-				// Do nothing, will pick up below
-				break
-			}
-		}
-
-		if err != nil {
-			// some other error
-			return err
+		if value, ok := info.Attributes[attr.name]; ok {
+			printWithLabel(attr.name, value)
 		}
-
-		printWithLabel(attr.name, attr.converter(template[0].Value))
 	}
 
 	fmt.Println()
-	return nil
 }
 
 func printWithLabel(label, value string) {
@@ -0,0 +1,408 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+package p11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// oidSubjectKeyID is the subject key identifier extension from RFC 5280.
+var oidSubjectKeyID = asn1.ObjectIdentifier{2, 5, 29, 14}
+
+// CertificateInfo describes an X.509 certificate object on the token. Only Label, ID and Raw are set for a certificate
+// which cannot be parsed.
+type CertificateInfo struct {
+	Label        string    `json:"label" yaml:"label"`
+	ID           HexBytes  `json:"id" yaml:"id"`
+	Subject      string    `json:"subject" yaml:"subject"`
+	Issuer       string    `json:"issuer" yaml:"issuer"`
+	SerialNumber string    `json:"serialNumber" yaml:"serialNumber"`
+	NotBefore    time.Time `json:"notBefore" yaml:"notBefore"`
+	NotAfter     time.Time `json:"notAfter" yaml:"notAfter"`
+
+	// KeyMatch is true if the public key with the same CKA_ID as the certificate, or failing that any public key on the
+	// token, has the value in the certificate
+	KeyMatch bool `json:"keyMatch" yaml:"keyMatch"`
+
+	// Raw is the DER encoded certificate
+	Raw []byte `json:"-" yaml:"-"`
+}
+
+// certificate holds the fields of an X.509 certificate used on the token. It is read with a minimal parse of the
+// TBSCertificate, as x509.ParseCertificate rejects certificates for secp256k1 keys.
+type certificate struct {
+	Raw          []byte
+	RawSubject   []byte
+	RawIssuer    []byte
+	SerialNumber *big.Int
+	NotBefore    time.Time
+	NotAfter     time.Time
+	SubjectKeyID []byte
+
+	PublicKeyAlgorithm pkix.AlgorithmIdentifier
+	PublicKey          asn1.BitString
+}
+
+// tbsCertificate is the TBSCertificate structure from RFC 5280.
+type tbsCertificate struct {
+	Version            int `asn1:"optional,explicit,default:0,tag:0"`
+	SerialNumber       *big.Int
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Issuer             asn1.RawValue
+	Validity           struct{ NotBefore, NotAfter time.Time }
+	Subject            asn1.RawValue
+	PublicKey          struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	IssuerUniqueID  asn1.BitString   `asn1:"optional,tag:1"`
+	SubjectUniqueID asn1.BitString   `asn1:"optional,tag:2"`
+	Extensions      []pkix.Extension `asn1:"optional,explicit,tag:3"`
+}
+
+// parseCertificate reads the fields of certificate from der, a DER encoded X.509 certificate.
+func parseCertificate(der []byte) (*certificate, error) {
+	var signed struct {
+		TBSCertificate     asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		SignatureValue     asn1.BitString
+	}
+	if rest, err := asn1.Unmarshal(der, &signed); err != nil {
+		return nil, errors.WithMessage(err, "invalid certificate")
+	} else if len(rest) > 0 {
+		return nil, errors.New("invalid certificate: trailing data")
+	}
+
+	var tbs tbsCertificate
+	if _, err := asn1.Unmarshal(signed.TBSCertificate.FullBytes, &tbs); err != nil {
+		return nil, errors.WithMessage(err, "invalid certificate")
+	}
+	if tbs.SerialNumber == nil {
+		return nil, errors.New("invalid certificate: no serial number")
+	}
+
+	cert := &certificate{
+		Raw:                der,
+		RawSubject:         tbs.Subject.FullBytes,
+		RawIssuer:          tbs.Issuer.FullBytes,
+		SerialNumber:       tbs.SerialNumber,
+		NotBefore:          tbs.Validity.NotBefore,
+		NotAfter:           tbs.Validity.NotAfter,
+		PublicKeyAlgorithm: tbs.PublicKey.Algorithm,
+		PublicKey:          tbs.PublicKey.PublicKey,
+	}
+
+	for _, ext := range tbs.Extensions {
+		if ext.Id.Equal(oidSubjectKeyID) {
+			if _, err := asn1.Unmarshal(ext.Value, &cert.SubjectKeyID); err != nil {
+				return nil, errors.WithMessage(err, "invalid subject key identifier")
+			}
+		}
+	}
+
+	return cert, nil
+}
+
+// nameString returns the RFC 2253 form of a DER encoded distinguished name, as pkix.Name.String does.
+func nameString(raw []byte) string {
+	var rdns pkix.RDNSequence
+	if _, err := asn1.Unmarshal(raw, &rdns); err != nil {
+		return ""
+	}
+
+	var name pkix.Name
+	name.FillFromRDNSequence(&rdns)
+	return name.String()
+}
+
+func (p *p11Token) ImportCertificates(label string, keyid string, chain [][]byte) (imported int, err error) {
+	if len(chain) == 0 {
+		return 0, errors.New("no certificates to import")
+	}
+
+	certs := make([]*certificate, len(chain))
+	for i, der := range chain {
+		cert, err := parseCertificate(der)
+		if err != nil {
+			return 0, errors.WithMessagef(err, "failed to parse certificate %d", i+1)
+		}
+		certs[i] = cert
+	}
+
+	publicKey, err := p.findKey(pkcs11.CKO_PUBLIC_KEY, label, keyid)
+	if err != nil {
+		return 0, err
+	}
+
+	attrs, err := p.ctx.GetAttributeValue(p.session, publicKey, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+	})
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to get key label and id")
+	}
+	keyLabel, id := string(attrs[0].Value), attrs[1].Value
+
+	match, err := p.keyMatchesCertificate(publicKey, certs[0])
+	if err != nil {
+		return 0, err
+	}
+	if !match {
+		return 0, errors.New("certificate does not match the key pair")
+	}
+
+	for i, cert := range certs {
+		certLabel, certID := keyLabel, id
+		if i > 0 {
+			// CA certificates are identified by their subject key id, as their keys are not on the token
+			certLabel, certID = fmt.Sprintf("%s-chain-%d", keyLabel, i), cert.SubjectKeyID
+		}
+
+		existing, err := p.findCertificate(cert)
+		if err != nil {
+			return imported, err
+		}
+		if existing {
+			continue
+		}
+
+		if err := p.createCertificate(certLabel, certID, cert); err != nil {
+			return imported, err
+		}
+		imported++
+	}
+
+	return imported, nil
+}
+
+// findCertificate reports whether a certificate with the issuer and serial number of cert is already on the token.
+func (p *p11Token) findCertificate(cert *certificate) (bool, error) {
+	serial, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {
+		return false, err
+	}
+
+	objects, err := p.findAllMatching([]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_ISSUER, cert.RawIssuer),
+		pkcs11.NewAttribute(pkcs11.CKA_SERIAL_NUMBER, serial),
+	})
+	return len(objects) > 0, err
+}
+
+func (p *p11Token) createCertificate(label string, id []byte, cert *certificate) error {
+	serial, err := asn1.Marshal(cert.SerialNumber)
+	if err != nil {
+		return err
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		pkcs11.NewAttribute(pkcs11.CKA_SUBJECT, cert.RawSubject),
+		pkcs11.NewAttribute(pkcs11.CKA_ISSUER, cert.RawIssuer),
+		pkcs11.NewAttribute(pkcs11.CKA_SERIAL_NUMBER, serial),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, cert.Raw),
+	}
+
+	_, err = p.ctx.CreateObject(p.session, template)
+	return errors.WithMessagef(err, "failed to create certificate %s", label)
+}
+
+func (p *p11Token) ListCertificates(label string, keyid string) ([]CertificateInfo, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_CERTIFICATE_TYPE, pkcs11.CKC_X_509),
+	}
+	if label != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+	if keyid != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, keyid))
+	}
+
+	objects, err := p.findAllMatching(template)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]CertificateInfo, 0, len(objects))
+	for _, o := range objects {
+		attrs, err := p.ctx.GetAttributeValue(p.session, o, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+		})
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to read certificate")
+		}
+
+		info := CertificateInfo{
+			Label: string(attrs[0].Value),
+			ID:    attrs[1].Value,
+			Raw:   attrs[2].Value,
+		}
+
+		// A certificate which cannot be parsed is still listed, so that it can be exported
+		cert, err := parseCertificate(info.Raw)
+		if err != nil {
+			res = append(res, info)
+			continue
+		}
+
+		info.Subject = nameString(cert.RawSubject)
+		info.Issuer = nameString(cert.RawIssuer)
+		info.SerialNumber = cert.SerialNumber.String()
+		info.NotBefore = cert.NotBefore
+		info.NotAfter = cert.NotAfter
+
+		if info.KeyMatch, err = p.certificateKeyMatch(cert, info.ID); err != nil {
+			return nil, err
+		}
+
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// certificateKeyMatch reports whether the public key with CKA_ID id is the key in cert. If there is no such key, e.g.
+// for RSA key pairs which are generated without a CKA_ID, the public keys with the value in cert are checked instead.
+func (p *p11Token) certificateKeyMatch(cert *certificate, id []byte) (bool, error) {
+	if len(id) > 0 {
+		keys, err := p.findAllMatching([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, id),
+		})
+		if err != nil {
+			return false, err
+		}
+		if len(keys) == 1 {
+			return p.keyMatchesCertificate(keys[0], cert)
+		}
+	}
+
+	template := publicKeyTemplate(cert)
+	if template == nil {
+		return false, nil
+	}
+
+	keys, err := p.findAllMatching(template)
+	if err != nil {
+		return false, err
+	}
+	for _, key := range keys {
+		match, err := p.keyMatchesCertificate(key, cert)
+		if err != nil || match {
+			return match, err
+		}
+	}
+
+	return false, nil
+}
+
+// keyMatchesCertificate reports whether the public key object key has the value in cert. EC keys are compared by their
+// curve and point, as the x509 package cannot parse secp256k1 public keys.
+func (p *p11Token) keyMatchesCertificate(key pkcs11.ObjectHandle, cert *certificate) (bool, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to get key type")
+	}
+
+	keyType := uint(readAttributeAsULong(attrs[0].Value))
+	if keyType != pkcs11.CKK_EC {
+		pub, err := p.publicKeyOfType(key, keyType)
+		if err != nil {
+			return false, err
+		}
+
+		certPub, err := x509.ParsePKIXPublicKey(cert.publicKeyInfo())
+		return err == nil && publicKeysEqual(pub, certPub), nil
+	}
+
+	if !cert.PublicKeyAlgorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return false, nil
+	}
+
+	attrs, err = p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+	})
+	if err != nil {
+		return false, errors.WithMessage(err, "failed to get EC params")
+	}
+	if !bytes.Equal(attrs[0].Value, cert.PublicKeyAlgorithm.Parameters.FullBytes) {
+		return false, nil
+	}
+
+	return bytes.Equal(ecPoint(p.ctx, p.session, key), cert.PublicKey.RightAlign()), nil
+}
+
+// publicKeyInfo returns the DER encoded SubjectPublicKeyInfo of cert.
+func (c *certificate) publicKeyInfo() []byte {
+	der, _ := asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{c.PublicKeyAlgorithm, c.PublicKey})
+	return der
+}
+
+// publicKeyTemplate returns a template to find the public key objects with the value in cert, or nil if it is not an
+// RSA or EC key.
+func publicKeyTemplate(cert *certificate) []*pkcs11.Attribute {
+	if cert.PublicKeyAlgorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		point, err := asn1.Marshal(cert.PublicKey.RightAlign())
+		if err != nil {
+			return nil
+		}
+		return []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, cert.PublicKeyAlgorithm.Parameters.FullBytes),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+		}
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(cert.publicKeyInfo())
+	if rsaPub, ok := pub.(*rsa.PublicKey); err == nil && ok {
+		return []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, rsaPub.N.Bytes()),
+		}
+	}
+
+	return nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	key, ok := a.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && key.Equal(b)
+}
@@ -0,0 +1,482 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"testing"
+	"time"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+// selfSignedCertificate returns a certificate for key, signed by itself.
+func selfSignedCertificate(t *testing.T, key crypto.Signer) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: "device-1234"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return cert
+}
+
+// secp256k1Signer signs with an in-memory secp256k1 key, returning ASN.1 signatures as KeySigner does.
+type secp256k1Signer struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s secp256k1Signer) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s secp256k1Signer) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	sig, err := ethcrypto.Sign(digest, s.key)
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(struct{ R, S *big.Int }{new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:64])})
+}
+
+// secp256k1Certificate returns a DER encoded certificate for key, signed by itself, which x509.ParseCertificate
+// rejects.
+func secp256k1Certificate(t *testing.T, key *ecdsa.PrivateKey) []byte {
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(43),
+		Subject:      pkix.Name{CommonName: "device-1234"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &placeholder.PublicKey, placeholder)
+	require.NoError(t, err)
+
+	der, err = replaceKeyAndSign(der, &placeholder.PublicKey, secp256k1Signer{key})
+	require.NoError(t, err)
+
+	_, err = x509.ParseCertificate(der)
+	require.Error(t, err)
+	return der
+}
+
+// expectP256PublicKey sets up the reads of a P-256 public key object.
+func expectP256PublicKey(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, handle pkcs11.ObjectHandle,
+	pub *ecdsa.PublicKey) {
+	params, _ := asn1.Marshal(p256OID)
+
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)}}).
+		Return([]*pkcs11.Attribute{ecPointAttribute(elliptic.Marshal(elliptic.P256(), pub.X, pub.Y))}, nil)
+}
+
+// expectCertificateLookup sets up the search made by ImportCertificates for a certificate with the issuer and serial
+// number of cert, which returns handles.
+func expectCertificateLookup(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, cert *x509.Certificate,
+	handles ...pkcs11.ObjectHandle) []*gomock.Call {
+	serial, _ := asn1.Marshal(cert.SerialNumber)
+	return expectFind(mockTokenCtx, session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_ISSUER, cert.RawIssuer),
+		pkcs11.NewAttribute(pkcs11.CKA_SERIAL_NUMBER, serial),
+	}, handles...)
+}
+
+func TestParseCertificate(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          new(big.Int).Lsh(big.NewInt(1), 150),
+		Subject:               pkix.Name{CommonName: "DIMO CA", Organization: []string{"DIMO"}},
+		NotBefore:             time.Now().Add(-time.Hour).Truncate(time.Second),
+		NotAfter:              time.Now().AddDate(10, 0, 0).Truncate(time.Second),
+		SubjectKeyId:          []byte{1, 2, 3, 4},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	expected, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	cert, err := parseCertificate(der)
+	require.NoError(t, err)
+	require.Equal(t, expected.RawSubject, cert.RawSubject)
+	require.Equal(t, expected.RawIssuer, cert.RawIssuer)
+	require.Equal(t, expected.RawSubjectPublicKeyInfo, cert.publicKeyInfo())
+	require.Equal(t, expected.SerialNumber, cert.SerialNumber)
+	require.True(t, expected.NotBefore.Equal(cert.NotBefore))
+	require.True(t, expected.NotAfter.Equal(cert.NotAfter))
+	require.Equal(t, []byte{1, 2, 3, 4}, cert.SubjectKeyID)
+	require.Equal(t, expected.Subject.String(), nameString(cert.RawSubject))
+
+	secp256k1Key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	cert, err = parseCertificate(secp256k1Certificate(t, secp256k1Key))
+	require.NoError(t, err)
+	require.Equal(t, ethcrypto.FromECDSAPub(&secp256k1Key.PublicKey), cert.PublicKey.RightAlign())
+
+	_, err = parseCertificate(der[:len(der)-1])
+	require.Error(t, err)
+
+	_, err = parseCertificate(append(der, 0))
+	require.Error(t, err)
+}
+
+// expectSecp256k1PublicKey sets up the reads of a secp256k1 public key object made when comparing it with a certificate.
+func expectSecp256k1PublicKey(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, handle pkcs11.ObjectHandle,
+	pub *ecdsa.PublicKey) {
+	expectKeyCurve(mockTokenCtx, session, handle, pkcs11.CKK_EC, secp256k1OID)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)}}).
+		Return([]*pkcs11.Attribute{ecPointAttribute(ethcrypto.FromECDSAPub(pub))}, nil)
+}
+
+func TestP11Token_ImportCertificates(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	const publicHandle = pkcs11.ObjectHandle(2)
+	keyID := []byte{1}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSignedCertificate(t, key)
+	serial, _ := asn1.Marshal(cert.SerialNumber)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	// The key lookup and the issuer/serial lookup are both made with FindObjects, so must be in one sequence
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+		}, nil))
+	calls = append(calls, expectCertificateLookup(mockTokenCtx, session, cert)...)
+	calls = append(calls, mockTokenCtx.EXPECT().CreateObject(session, attributeMatcher{[]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, keyLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+		pkcs11.NewAttribute(pkcs11.CKA_SERIAL_NUMBER, serial),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, cert.Raw),
+	}}).Return(pkcs11.ObjectHandle(3), nil))
+	gomock.InOrder(calls...)
+	expectP256PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	imported, err := p11Token.ImportCertificates(keyLabel, "", [][]byte{cert.Raw})
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+}
+
+func TestP11Token_ImportCertificates_AlreadyOnToken(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const publicHandle = pkcs11.ObjectHandle(2)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSignedCertificate(t, key)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "somekey"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{1}),
+		}, nil))
+	calls = append(calls, expectCertificateLookup(mockTokenCtx, session, cert, pkcs11.ObjectHandle(3))...)
+	gomock.InOrder(calls...)
+	expectP256PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	// The certificate is found, so no object is created
+	mockTokenCtx.EXPECT().CreateObject(gomock.Any(), gomock.Any()).Times(0)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	imported, err := p11Token.ImportCertificates("somekey", "", [][]byte{cert.Raw})
+	require.NoError(t, err)
+	require.Zero(t, imported)
+}
+
+func TestP11Token_ImportCertificates_Secp256k1(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const publicHandle = pkcs11.ObjectHandle(2)
+	keyID := []byte{1}
+
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	der := secp256k1Certificate(t, key)
+	cert, err := parseCertificate(der)
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	serial, _ := asn1.Marshal(cert.SerialNumber)
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "clitest"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+		}, nil))
+	calls = append(calls, expectFind(mockTokenCtx, session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_ISSUER, cert.RawIssuer),
+		pkcs11.NewAttribute(pkcs11.CKA_SERIAL_NUMBER, serial),
+	})...)
+	calls = append(calls, mockTokenCtx.EXPECT().CreateObject(session, attributeMatcher{[]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_CERTIFICATE),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, "clitest"),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+		pkcs11.NewAttribute(pkcs11.CKA_SUBJECT, cert.RawSubject),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, der),
+	}}).Return(pkcs11.ObjectHandle(3), nil))
+	gomock.InOrder(calls...)
+	expectSecp256k1PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	imported, err := p11Token.ImportCertificates("clitest", "", [][]byte{der})
+	require.NoError(t, err)
+	require.Equal(t, 1, imported)
+}
+
+func TestP11Token_ImportCertificates_WrongKey(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const publicHandle = pkcs11.ObjectHandle(2)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "somekey"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{1}),
+		}, nil)
+	expectP256PublicKey(mockTokenCtx, session, publicHandle, &otherKey.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	_, err = p11Token.ImportCertificates("somekey", "", [][]byte{selfSignedCertificate(t, key).Raw})
+	require.EqualError(t, err, "certificate does not match the key pair")
+}
+
+func TestP11Token_ListCertificates(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const certHandle = pkcs11.ObjectHandle(3)
+	const publicHandle = pkcs11.ObjectHandle(2)
+	keyID := []byte{1}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	cert := selfSignedCertificate(t, key)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_CERTIFICATE, certHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, certHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "somekey"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, cert.Raw),
+		}, nil)
+
+	mockTokenCtx.EXPECT().FindObjectsInit(session, attributeMatcher{[]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+	}}).Return(nil)
+	call1 := mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return([]pkcs11.ObjectHandle{publicHandle}, false, nil)
+	mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(nil, false, nil).After(call1)
+	mockTokenCtx.EXPECT().FindObjectsFinal(session).Return(nil)
+	expectP256PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	certs, err := p11Token.ListCertificates("", "")
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	require.Equal(t, "somekey", certs[0].Label)
+	require.Equal(t, HexBytes(keyID), certs[0].ID)
+	require.Equal(t, "CN=device-1234", certs[0].Subject)
+	require.Equal(t, "42", certs[0].SerialNumber)
+	require.True(t, certs[0].KeyMatch)
+}
+
+func TestP11Token_ListCertificates_NoKeyID(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const certHandle = pkcs11.ObjectHandle(3)
+	const publicHandle = pkcs11.ObjectHandle(2)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	cert := selfSignedCertificate(t, key)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_CERTIFICATE, certHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, certHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "rsakey"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, cert.Raw),
+		}, nil)
+
+	// Without a CKA_ID, the public key is found by its modulus
+	gomock.InOrder(expectFind(mockTokenCtx, session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+	}, publicHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()),
+		}, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	certs, err := p11Token.ListCertificates("", "")
+	require.NoError(t, err)
+	require.Len(t, certs, 1)
+	require.Empty(t, certs[0].ID)
+	require.True(t, certs[0].KeyMatch)
+}
+
+func TestP11Token_ListCertificates_Secp256k1(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const publicHandle = pkcs11.ObjectHandle(2)
+	const certHandle = pkcs11.ObjectHandle(3)
+	const invalidHandle = pkcs11.ObjectHandle(4)
+	keyID := []byte{1}
+
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+	der := secp256k1Certificate(t, key)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_CERTIFICATE, certHandle, invalidHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, certHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "clitest"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, der),
+		}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, invalidHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "invalid"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE, []byte("not a certificate")),
+		}, nil)
+
+	gomock.InOrder(expectFind(mockTokenCtx, session, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyID),
+	}, publicHandle)...)
+	expectSecp256k1PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	certs, err := p11Token.ListCertificates("", "")
+	require.NoError(t, err)
+	require.Len(t, certs, 2)
+
+	require.Equal(t, "CN=device-1234", certs[0].Subject)
+	require.Equal(t, "43", certs[0].SerialNumber)
+	require.Equal(t, der, certs[0].Raw)
+	require.True(t, certs[0].KeyMatch)
+
+	// The certificate which can't be parsed is still listed
+	require.Equal(t, "invalid", certs[1].Label)
+	require.Equal(t, []byte("not a certificate"), certs[1].Raw)
+	require.Empty(t, certs[1].Subject)
+	require.False(t, certs[1].KeyMatch)
+}
@@ -0,0 +1,178 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/rand"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// CipherMode is an AES mode supported by Encrypt and Decrypt.
+type CipherMode string
+
+// Supported cipher modes
+const (
+	// ModeGCM is AES-GCM with a 12 byte nonce and a 16 byte tag, with optional additional authenticated data
+	ModeGCM CipherMode = "gcm"
+	// ModeCBCPad is AES-CBC with PKCS #7 padding and a 16 byte IV
+	ModeCBCPad CipherMode = "cbc-pad"
+	// ModeCTR is AES-CTR with a 16 byte initial counter block, all of which is incremented
+	ModeCTR CipherMode = "ctr"
+)
+
+// envelopeVersion is the first byte of the envelope written by Encrypt.
+const envelopeVersion = 1
+
+// cipherModes holds the envelope mode byte and IV length of each mode.
+var cipherModes = map[CipherMode]struct {
+	id    byte
+	ivLen int
+}{
+	ModeGCM:    {1, 12},
+	ModeCBCPad: {2, 16},
+	ModeCTR:    {3, 16},
+}
+
+const gcmTagBits = 128
+
+// ParseCipherMode returns the mode with the given name: gcm, cbc-pad or ctr.
+func ParseCipherMode(name string) (CipherMode, error) {
+	mode := CipherMode(name)
+	if _, ok := cipherModes[mode]; !ok {
+		return "", errors.Errorf("unsupported cipher mode '%s'", name)
+	}
+	return mode, nil
+}
+
+// Encrypt encrypts plaintext with a fresh random IV and returns the envelope
+//
+//	version (1 byte, 1) || mode (1 byte: 1 GCM, 2 CBC-PAD, 3 CTR) || IV length (1 byte) || IV || ciphertext
+//
+// For GCM the ciphertext ends with the 16 byte tag. aad is only used with GCM, and must be given again to Decrypt.
+func (p *p11Token) Encrypt(label string, keyid string, mode CipherMode, plaintext []byte, aad []byte) ([]byte, error) {
+	m, ok := cipherModes[mode]
+	if !ok {
+		return nil, errors.Errorf("unsupported cipher mode '%s'", mode)
+	}
+	if len(aad) > 0 && mode != ModeGCM {
+		return nil, errors.New("additional authenticated data is only supported with GCM")
+	}
+
+	object, err := p.findKey(pkcs11.CKO_SECRET_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	iv := make([]byte, m.ivLen)
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	mech, free := cipherMechanism(mode, iv, aad)
+	defer free()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ctx.EncryptInit(p.session, []*pkcs11.Mechanism{mech}, object); err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise encryption")
+	}
+
+	ciphertext, err := p.ctx.Encrypt(p.session, plaintext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to encrypt")
+	}
+
+	envelope := append([]byte{envelopeVersion, m.id, byte(len(iv))}, iv...)
+	return append(envelope, ciphertext...), nil
+}
+
+// Decrypt decrypts an envelope written by Encrypt.
+func (p *p11Token) Decrypt(label string, keyid string, envelope []byte, aad []byte) ([]byte, error) {
+	mode, iv, ciphertext, err := parseEnvelope(envelope)
+	if err != nil {
+		return nil, err
+	}
+
+	object, err := p.findKey(pkcs11.CKO_SECRET_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	mech, free := cipherMechanism(mode, iv, aad)
+	defer free()
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ctx.DecryptInit(p.session, []*pkcs11.Mechanism{mech}, object); err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise decryption")
+	}
+
+	plaintext, err := p.ctx.Decrypt(p.session, ciphertext)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to decrypt")
+	}
+
+	return plaintext, nil
+}
+
+func parseEnvelope(envelope []byte) (mode CipherMode, iv []byte, ciphertext []byte, err error) {
+	if len(envelope) < 3 || envelope[0] != envelopeVersion {
+		err = errors.New("not an encrypted envelope")
+		return
+	}
+
+	for name, m := range cipherModes {
+		if m.id == envelope[1] {
+			mode = name
+		}
+	}
+	if mode == "" {
+		err = errors.Errorf("unsupported cipher mode %d in envelope", envelope[1])
+		return
+	}
+
+	ivLen := int(envelope[2])
+	if ivLen != cipherModes[mode].ivLen || len(envelope) < 3+ivLen {
+		err = errors.New("invalid IV in envelope")
+		return
+	}
+
+	return mode, envelope[3 : 3+ivLen], envelope[3+ivLen:], nil
+}
+
+// cipherMechanism returns the mechanism for mode, and a function to free its parameters once used.
+func cipherMechanism(mode CipherMode, iv []byte, aad []byte) (*pkcs11.Mechanism, func()) {
+	switch mode {
+	case ModeGCM:
+		params := pkcs11.NewGCMParams(iv, aad, gcmTagBits)
+		return pkcs11.NewMechanism(pkcs11.CKM_AES_GCM, params), params.Free
+	case ModeCBCPad:
+		return pkcs11.NewMechanism(pkcs11.CKM_AES_CBC_PAD, iv), func() {}
+	default:
+		return pkcs11.NewMechanism(pkcs11.CKM_AES_CTR, ctrParams(iv)), func() {}
+	}
+}
+
+// ctrParams returns CK_AES_CTR_PARAMS for a 128 bit counter starting at block. The CK_ULONG counter size is
+// encoded the same way as ulong attribute values.
+func ctrParams(block []byte) []byte {
+	counterBits := pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, uint(128)).Value
+	return append(counterBits, block...)
+}
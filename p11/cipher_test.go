@@ -0,0 +1,88 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_EncryptDecrypt(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	const keyHandle = pkcs11.ObjectHandle(1)
+	plaintext := []byte("telemetry")
+	ciphertext := []byte("ciphertext and tag")
+	aad := []byte("device-1234")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, keyHandle)...)
+	mockTokenCtx.EXPECT().EncryptInit(session, gomock.Any(), keyHandle).
+		Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _ pkcs11.ObjectHandle) {
+			require.Equal(t, uint(pkcs11.CKM_AES_GCM), m[0].Mechanism)
+		}).Return(nil)
+	mockTokenCtx.EXPECT().Encrypt(session, plaintext).Return(ciphertext, nil)
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, keyHandle)...)
+	mockTokenCtx.EXPECT().DecryptInit(session, gomock.Any(), keyHandle).Return(nil)
+	mockTokenCtx.EXPECT().Decrypt(session, ciphertext).Return(plaintext, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	envelope, err := p11Token.Encrypt(keyLabel, "", ModeGCM, plaintext, aad)
+	require.NoError(t, err)
+	require.Equal(t, []byte{envelopeVersion, 1, 12}, envelope[:3])
+	require.Equal(t, ciphertext, envelope[15:])
+
+	decrypted, err := p11Token.Decrypt(keyLabel, "", envelope, aad)
+	require.NoError(t, err)
+	require.Equal(t, plaintext, decrypted)
+}
+
+func TestP11Token_Encrypt_AADRequiresGCM(t *testing.T) {
+	mockCtrl, mockTokenCtx, _ := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	_, err = p11Token.Encrypt("somekey", "", ModeCBCPad, []byte("telemetry"), []byte("aad"))
+	require.Error(t, err)
+}
+
+func TestParseEnvelope(t *testing.T) {
+	iv := make([]byte, 16)
+	mode, parsedIV, ciphertext, err := parseEnvelope(append(append([]byte{envelopeVersion, 3, 16}, iv...), 1, 2))
+	require.NoError(t, err)
+	require.Equal(t, ModeCTR, mode)
+	require.Equal(t, iv, parsedIV)
+	require.Equal(t, []byte{1, 2}, ciphertext)
+
+	_, _, _, err = parseEnvelope([]byte{envelopeVersion, 1, 16})
+	require.Error(t, err)
+
+	_, _, _, err = parseEnvelope([]byte{2, 1, 12})
+	require.Error(t, err)
+}
@@ -128,6 +128,8 @@ func keyTypeToString(keyType uint) (res string, err error) {
 		res = "CKK_GOSTR3411"
 	case pkcs11.CKK_GOST28147:
 		res = "CKK_GOST28147"
+	case ckkECEdwards:
+		res = "CKK_EC_EDWARDS"
 	case pkcs11.CKK_VENDOR_DEFINED:
 		res = "CKK_VENDOR_DEFINED"
 	default:
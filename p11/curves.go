@@ -0,0 +1,165 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"strings"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// PKCS #11 v3.0 values for Edwards curve keys, which are not defined by miekg/pkcs11.
+const (
+	ckkECEdwards           = 0x40
+	ckmECEdwardsKeyPairGen = 0x1055
+	ckmEdDSA               = 0x1057
+)
+
+// Curve is an elliptic curve supported for key generation, signing and verification.
+type Curve string
+
+// Supported curves
+const (
+	CurveSecp256k1 Curve = "secp256k1"
+	CurveP256      Curve = "P-256"
+	CurveP384      Curve = "P-384"
+	CurveEd25519   Curve = "Ed25519"
+)
+
+var ed25519OID = asn1.ObjectIdentifier{1, 3, 101, 112}
+
+// curveOIDs are the named curve OIDs used in CKA_EC_PARAMS.
+var curveOIDs = map[Curve]asn1.ObjectIdentifier{
+	CurveSecp256k1: secp256k1OID,
+	CurveP256:      p256OID,
+	CurveP384:      p384OID,
+	CurveEd25519:   ed25519OID,
+}
+
+// curveAliases maps the lower case names accepted by ParseCurve to curves.
+var curveAliases = map[string]Curve{
+	"secp256k1":  CurveSecp256k1,
+	"s256":       CurveSecp256k1,
+	"p-256":      CurveP256,
+	"p256":       CurveP256,
+	"secp256r1":  CurveP256,
+	"prime256v1": CurveP256,
+	"p-384":      CurveP384,
+	"p384":       CurveP384,
+	"secp384r1":  CurveP384,
+	"ed25519":    CurveEd25519,
+}
+
+// ParseCurve returns the curve with the given name, such as S256, secp256k1, P256, P-384 or Ed25519.
+func ParseCurve(name string) (Curve, error) {
+	if curve, ok := curveAliases[strings.ToLower(name)]; ok {
+		return curve, nil
+	}
+	return "", errors.Errorf("unsupported curve '%s'", name)
+}
+
+// bits returns the size of the curve.
+func (c Curve) bits() int {
+	if c == CurveP384 {
+		return 384
+	}
+	return 256
+}
+
+// keyType returns the CKK_ key type of keys on the curve.
+func (c Curve) keyType() uint {
+	if c == CurveEd25519 {
+		return ckkECEdwards
+	}
+	return pkcs11.CKK_EC
+}
+
+// digest returns what is signed for message with a key on the curve: its Keccak-256 hash for secp256k1, as Ethereum
+// uses, the SHA-2 hash of the curve size for the NIST curves, and the message itself for Ed25519.
+func (c Curve) digest(message []byte) []byte {
+	switch c {
+	case CurveSecp256k1:
+		return crypto.Keccak256(message)
+	case CurveP256:
+		sum := sha256.Sum256(message)
+		return sum[:]
+	case CurveP384:
+		sum := sha512.Sum384(message)
+		return sum[:]
+	default:
+		return message
+	}
+}
+
+// curveFromParams returns the curve named in DER encoded CKA_EC_PARAMS. Some tokens give Edwards curves by a
+// PrintableString name rather than an OID.
+func curveFromParams(params []byte) (Curve, error) {
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err == nil {
+		for curve, curveOID := range curveOIDs {
+			if oid.Equal(curveOID) {
+				return curve, nil
+			}
+		}
+		return "", errors.Errorf("unsupported curve %s", oid)
+	}
+
+	var name string
+	if _, err := asn1.Unmarshal(params, &name); err == nil && name == "edwards25519" {
+		return CurveEd25519, nil
+	}
+
+	return "", errors.New("EC params are not a named curve")
+}
+
+// keyCurve returns the curve of the EC or Edwards key object.
+func (p *p11Token) keyCurve(key pkcs11.ObjectHandle) (Curve, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to get key type")
+	}
+
+	keyType := uint(readAttributeAsULong(attrs[0].Value))
+	if keyType != pkcs11.CKK_EC && keyType != ckkECEdwards {
+		keyTypeName, _ := keyTypeToString(keyType)
+		return "", errors.Errorf("unsupported key type %s", keyTypeName)
+	}
+
+	attrs, err = p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to get EC params")
+	}
+
+	return curveFromParams(attrs[0].Value)
+}
+
+// decodeEdwardsPoint returns the public key from an Edwards curve CKA_EC_POINT, which is usually a DER OCTET STRING.
+func decodeEdwardsPoint(value []byte) []byte {
+	var point []byte
+	if rest, err := asn1.Unmarshal(value, &point); err == nil && len(rest) == 0 {
+		return point
+	}
+	return value
+}
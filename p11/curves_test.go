@@ -0,0 +1,188 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/asn1"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseCurve(t *testing.T) {
+	for name, expected := range map[string]Curve{
+		"S256":       CurveSecp256k1,
+		"secp256k1":  CurveSecp256k1,
+		"P256":       CurveP256,
+		"prime256v1": CurveP256,
+		"P-384":      CurveP384,
+		"ed25519":    CurveEd25519,
+	} {
+		curve, err := ParseCurve(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, curve)
+	}
+
+	_, err := ParseCurve("P-521")
+	require.Error(t, err)
+}
+
+func TestCurveFromParams(t *testing.T) {
+	params, _ := asn1.Marshal(ed25519OID)
+	curve, err := curveFromParams(params)
+	require.NoError(t, err)
+	require.Equal(t, CurveEd25519, curve)
+
+	params, _ = asn1.MarshalWithParams("edwards25519", "printable")
+	curve, err = curveFromParams(params)
+	require.NoError(t, err)
+	require.Equal(t, CurveEd25519, curve)
+
+	params, _ = asn1.Marshal(asn1.ObjectIdentifier{1, 2, 3})
+	_, err = curveFromParams(params)
+	require.Error(t, err)
+}
+
+func TestP11Token_SignMessage_P256(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	const privateHandle = pkcs11.ObjectHandle(1)
+	const publicHandle = pkcs11.ObjectHandle(2)
+	message := []byte("testmessage")
+	digest := sha256.Sum256(message)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	expected := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)...)
+	expectKeyCurve(mockTokenCtx, session, privateHandle, pkcs11.CKK_EC, p256OID)
+	mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)},
+		privateHandle).Return(nil)
+	mockTokenCtx.EXPECT().Sign(session, digest[:]).Return(expected, nil)
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)...)
+	expectP256PublicKey(mockTokenCtx, session, publicHandle, &key.PublicKey)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signature, err := p11Token.SignMessage(keyLabel, "", message)
+	require.NoError(t, err)
+	require.Equal(t, expected, signature)
+
+	require.NoError(t, p11Token.Verify(keyLabel, "", digest[:], signature))
+}
+
+func TestP11Token_Sign_P256HashLength(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const privateHandle = pkcs11.ObjectHandle(1)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	// Nothing is signed
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)...)
+	expectKeyCurve(mockTokenCtx, session, privateHandle, pkcs11.CKK_EC, p256OID)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	_, err = p11Token.Sign("somekey", "", make([]byte, 20))
+	require.Error(t, err)
+}
+
+func TestP11Token_SignMessage_Ed25519(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	const privateHandle = pkcs11.ObjectHandle(1)
+	const publicHandle = pkcs11.ObjectHandle(2)
+	message := []byte("testmessage")
+
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	expected := ed25519.Sign(priv, message)
+	point, _ := asn1.Marshal([]byte(pub))
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)...)
+	expectKeyCurve(mockTokenCtx, session, privateHandle, ckkECEdwards, ed25519OID)
+	mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(ckmEdDSA, nil)},
+		privateHandle).Return(nil)
+	mockTokenCtx.EXPECT().Sign(session, message).Return(expected, nil)
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)...)
+	params, _ := asn1.Marshal(ed25519OID)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).Times(2).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkECEdwards)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point)}, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signature, err := p11Token.SignMessage(keyLabel, "", message)
+	require.NoError(t, err)
+	require.Equal(t, expected, signature)
+
+	require.NoError(t, p11Token.VerifyMessage(keyLabel, "", message, signature))
+	require.Error(t, verifyWithKey(pub, []byte("othermessage"), signature))
+}
+
+func TestVerifyWithKey_P384(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	digest := CurveP384.digest([]byte("testmessage"))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	require.NoError(t, err)
+	signature := append(r.FillBytes(make([]byte, 48)), s.FillBytes(make([]byte, 48))...)
+
+	require.NoError(t, verifyWithKey(&key.PublicKey, digest, signature))
+
+	signature[0] ^= 0xff
+	require.Error(t, verifyWithKey(&key.PublicKey, digest, signature))
+	require.Error(t, verifyWithKey(&key.PublicKey, digest, signature[:64]))
+}
@@ -0,0 +1,254 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"strings"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// DeriveKey runs ECDH between the EC private key with the given label and/or key id and peer, and stores a keysize
+// bit AES key derived from the shared secret on the token, labelled derivedLabel. peer is a public key on the same
+// curve, as a PEM or DER SubjectPublicKeyInfo, a JWK or a hex encoded compressed or uncompressed point. The key is
+// derived with the ANSI X9.63 SHA-256 KDF over the shared secret and sharedInfo, unless raw is set, in which case the
+// first keysize bits of the x-coordinate are used as they are. The key is sensitive, and only extractable if
+// extractable is set.
+func (p *p11Token) DeriveKey(label string, keyid string, peer []byte, derivedLabel string, keysize int,
+	sharedInfo []byte, raw bool, extractable bool) error {
+	if !isValidSize([]int{128, 192, 256}, keysize) {
+		return errors.Errorf("Invalid AES key size: %d", keysize)
+	}
+	if raw && len(sharedInfo) > 0 {
+		return errors.New("shared info cannot be used with a raw shared secret")
+	}
+
+	private, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return err
+	}
+
+	curve, err := p.keyCurve(private)
+	if err != nil {
+		return err
+	}
+
+	point, err := parsePeerPoint(curve, peer)
+	if err != nil {
+		return err
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, keysize/8),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, derivedLabel),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, extractable),
+	}
+
+	_, err = p.deriveECDH(private, ecdhParams(point, sharedInfo, raw), template)
+	return err
+}
+
+// ecdhParams returns the ECDH parameters for the uncompressed peer point, using the SHA-256 KDF with sharedInfo
+// unless raw is set.
+func ecdhParams(point []byte, sharedInfo []byte, raw bool) *pkcs11.ECDH1DeriveParams {
+	if raw {
+		return pkcs11.NewECDH1DeriveParams(pkcs11.CKD_NULL, nil, point)
+	}
+	return pkcs11.NewECDH1DeriveParams(pkcs11.CKD_SHA256_KDF, sharedInfo, point)
+}
+
+// deriveECDH creates a key from template by ECDH between private and the peer point in params.
+func (p *p11Token) deriveECDH(private pkcs11.ObjectHandle, params *pkcs11.ECDH1DeriveParams,
+	template []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	mech := pkcs11.NewMechanism(pkcs11.CKM_ECDH1_DERIVE, params)
+
+	key, err := p.ctx.DeriveKey(p.session, []*pkcs11.Mechanism{mech}, private, template)
+	if err == pkcs11.Error(pkcs11.CKR_KEY_FUNCTION_NOT_PERMITTED) {
+		return 0, errors.New("key does not allow derivation (CKA_DERIVE), run enableDerive on it first")
+	}
+	if err == pkcs11.Error(pkcs11.CKR_MECHANISM_PARAM_INVALID) && params.KDF != pkcs11.CKD_NULL {
+		return 0, errors.New("the token does not support the SHA-256 KDF for ECDH, a raw shared secret is needed")
+	}
+	if err != nil {
+		return 0, errors.WithMessage(err, "failed to derive key")
+	}
+
+	return key, nil
+}
+
+// EnableDerive sets CKA_DERIVE on the EC private key with the given label and/or key id, so that it can be used for
+// ECDH. Keys generated before ECDH support was added do not have it set.
+func (p *p11Token) EnableDerive(label string, keyid string) error {
+	private, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return err
+	}
+
+	curve, err := p.keyCurve(private)
+	if err != nil {
+		return err
+	}
+	if curve == CurveEd25519 {
+		return errors.New("ECDH is not supported with Ed25519 keys")
+	}
+
+	err = p.ctx.SetAttributeValue(p.session, private, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true),
+	})
+	if err == pkcs11.Error(pkcs11.CKR_ATTRIBUTE_READ_ONLY) {
+		return errors.New("the token does not allow CKA_DERIVE to be changed on this key")
+	}
+	return errors.WithMessage(err, "failed to set CKA_DERIVE")
+}
+
+// parsePeerPoint returns the uncompressed point of peer, an EC public key on curve in any of the encodings accepted by
+// DeriveKey.
+func parsePeerPoint(curve Curve, peer []byte) ([]byte, error) {
+	if curve == CurveEd25519 {
+		return nil, errors.New("ECDH is not supported with Ed25519 keys")
+	}
+
+	// DER is binary, so only the text encodings are trimmed. Hex text starts with '0', which is also the first byte
+	// of a DER SEQUENCE, so DER is only tried once the text encodings have been ruled out.
+	text := bytes.TrimSpace(peer)
+
+	var point []byte
+	var err error
+	if hexPoint, hexErr := hex.DecodeString(strings.TrimPrefix(string(text), "0x")); hexErr == nil {
+		point = hexPoint
+	} else if bytes.HasPrefix(text, []byte("-----BEGIN")) {
+		block, _ := pem.Decode(text)
+		if block == nil {
+			return nil, errors.New("invalid PEM public key")
+		}
+		point, err = spkiPoint(curve, block.Bytes)
+	} else if bytes.HasPrefix(text, []byte("{")) {
+		point, err = jwkPoint(curve, text)
+	} else if len(peer) > 0 && peer[0] == 0x30 {
+		point, err = spkiPoint(curve, peer)
+	} else {
+		err = errors.New("peer key is not a PEM, DER, JWK or hex encoded public key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := unmarshalPoint(curve, point)
+	if err != nil {
+		return nil, err
+	}
+
+	return elliptic.Marshal(pub.Curve, pub.X, pub.Y), nil
+}
+
+// spkiPoint returns the point in a DER encoded SubjectPublicKeyInfo, which must be for curve.
+func spkiPoint(curve Curve, der []byte) ([]byte, error) {
+	var spki struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(der, &spki); err != nil {
+		return nil, errors.WithMessage(err, "invalid public key")
+	}
+
+	var oid asn1.ObjectIdentifier
+	if !spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA) {
+		return nil, errors.New("peer key is not an EC key")
+	}
+	if _, err := asn1.Unmarshal(spki.Algorithm.Parameters.FullBytes, &oid); err != nil || !oid.Equal(curveOIDs[curve]) {
+		return nil, errors.Errorf("peer key is not on curve %s", curve)
+	}
+
+	return spki.PublicKey.Bytes, nil
+}
+
+// jwkPoint returns the uncompressed point of an EC JSON Web Key, which must be for curve.
+func jwkPoint(curve Curve, data []byte) ([]byte, error) {
+	var key jwk
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, errors.WithMessage(err, "invalid JWK")
+	}
+	if key.Kty != "EC" || key.Crv != string(curve) {
+		return nil, errors.Errorf("peer key is not on curve %s", curve)
+	}
+
+	x, err := base64.RawURLEncoding.DecodeString(key.X)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid JWK x")
+	}
+	y, err := base64.RawURLEncoding.DecodeString(key.Y)
+	if err != nil {
+		return nil, errors.WithMessage(err, "invalid JWK y")
+	}
+
+	size := curve.bits() / 8
+	if len(x) > size || len(y) > size {
+		return nil, errors.New("invalid JWK coordinates")
+	}
+
+	point := make([]byte, 1+2*size)
+	point[0] = 0x04
+	copy(point[1+size-len(x):], x)
+	copy(point[1+2*size-len(y):], y)
+	return point, nil
+}
+
+// unmarshalPoint decodes a compressed or uncompressed point on curve, checking it is on the curve.
+func unmarshalPoint(curve Curve, point []byte) (*ecdsa.PublicKey, error) {
+	compressed := len(point) == 1+curve.bits()/8
+
+	if curve == CurveSecp256k1 {
+		if compressed {
+			return ethcrypto.DecompressPubkey(point)
+		}
+		return ethcrypto.UnmarshalPubkey(point)
+	}
+
+	ec := elliptic.P256()
+	if curve == CurveP384 {
+		ec = elliptic.P384()
+	}
+
+	var pub ecdsa.PublicKey
+	pub.Curve = ec
+	if compressed {
+		pub.X, pub.Y = elliptic.UnmarshalCompressed(ec, point)
+	} else {
+		pub.X, pub.Y = elliptic.Unmarshal(ec, point)
+	}
+	if pub.X == nil {
+		return nil, errors.Errorf("invalid point for curve %s", curve)
+	}
+
+	return &pub, nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_DeriveKey(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyHandle = pkcs11.ObjectHandle(1)
+	peer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(append(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, keyHandle),
+		expectKeyCurve(mockTokenCtx, session, keyHandle, pkcs11.CKK_EC, p256OID)...)...)
+	mockTokenCtx.EXPECT().DeriveKey(session, gomock.Any(), keyHandle,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 16),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "channel"),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+		}}).
+		Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _ pkcs11.ObjectHandle, _ []*pkcs11.Attribute) {
+			require.Equal(t, uint(pkcs11.CKM_ECDH1_DERIVE), m[0].Mechanism)
+		}).Return(pkcs11.ObjectHandle(2), nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	peerPEM, err := ExportPublicKey(&peer.PublicKey, PublicKeyPEM)
+	require.NoError(t, err)
+
+	err = p11Token.DeriveKey("device", "", peerPEM, "channel", 128, []byte("dimo"), false, false)
+	require.NoError(t, err)
+
+	err = p11Token.DeriveKey("device", "", peerPEM, "channel", 128, []byte("dimo"), true, false)
+	require.Error(t, err, "shared info needs a KDF")
+}
+
+func TestECDHParams(t *testing.T) {
+	point := []byte{0x04, 0x01, 0x02}
+
+	params := ecdhParams(point, []byte("dimo"), false)
+	require.Equal(t, uint(pkcs11.CKD_SHA256_KDF), params.KDF)
+	require.Equal(t, []byte("dimo"), params.SharedData)
+	require.Equal(t, point, params.PublicKeyData)
+
+	params = ecdhParams(point, nil, true)
+	require.Equal(t, uint(pkcs11.CKD_NULL), params.KDF)
+	require.Nil(t, params.SharedData)
+}
+
+func TestParsePeerPoint(t *testing.T) {
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	k1, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	for curve, key := range map[Curve]*ecdsa.PrivateKey{CurveP256: p256, CurveSecp256k1: k1} {
+		expected := elliptic.Marshal(key.Curve, key.X, key.Y)
+
+		for _, format := range []PublicKeyFormat{PublicKeyPEM, PublicKeyDER, PublicKeyJWK, PublicKeyHex,
+			PublicKeyHexCompressed} {
+			encoded, err := ExportPublicKey(&key.PublicKey, format)
+			require.NoError(t, err)
+
+			point, err := parsePeerPoint(curve, encoded)
+			require.NoError(t, err, "%s %s", curve, format)
+			require.Equal(t, expected, point, "%s %s", curve, format)
+		}
+	}
+
+	encoded, err := ExportPublicKey(&p256.PublicKey, PublicKeyJWK)
+	require.NoError(t, err)
+	_, err = parsePeerPoint(CurveSecp256k1, encoded)
+	require.Error(t, err)
+
+	_, err = parsePeerPoint(CurveEd25519, encoded)
+	require.Error(t, err)
+
+	_, err = parsePeerPoint(CurveP256, elliptic.Marshal(p256.Curve, p256.X, p256.Y))
+	require.Error(t, err, "raw binary points are not accepted")
+}
+
+func TestP11Token_EnableDerive(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyHandle = pkcs11.ObjectHandle(1)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	for _, result := range []error{nil, pkcs11.Error(pkcs11.CKR_ATTRIBUTE_READ_ONLY)} {
+		calls := append(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, keyHandle),
+			expectKeyCurve(mockTokenCtx, session, keyHandle, pkcs11.CKK_EC, secp256k1OID)...)
+		calls = append(calls, mockTokenCtx.EXPECT().SetAttributeValue(session, keyHandle,
+			attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_DERIVE, true)}}).Return(result))
+		gomock.InOrder(calls...)
+	}
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	require.NoError(t, p11Token.EnableDerive("device", ""))
+	require.Error(t, p11Token.EnableDerive("device", ""))
+}
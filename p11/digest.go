@@ -0,0 +1,99 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// digestChunkSize is the amount of data passed to each C_DigestUpdate.
+const digestChunkSize = 64 * 1024
+
+// digestMechanisms maps the names accepted by ParseDigestMechanism to mechanisms.
+var digestMechanisms = map[string]uint{
+	"sha256":   pkcs11.CKM_SHA256,
+	"sha384":   pkcs11.CKM_SHA384,
+	"sha512":   pkcs11.CKM_SHA512,
+	"sha3-256": pkcs11.CKM_SHA3_256,
+	"sha3-384": pkcs11.CKM_SHA3_384,
+	"sha3-512": pkcs11.CKM_SHA3_512,
+}
+
+// ParseDigestMechanism returns the digest mechanism with the given name: sha256, sha384, sha512, sha3-256, sha3-384
+// or sha3-512. Keccak-256, as Ethereum uses, is not a standard mechanism, so tokens that have it define their own;
+// give its number, such as 0x80000010, instead.
+func ParseDigestMechanism(name string) (uint, error) {
+	if mechanism, ok := digestMechanisms[strings.ToLower(name)]; ok {
+		return mechanism, nil
+	}
+	if mechanism, err := strconv.ParseUint(name, 0, 64); err == nil && mechanism >= pkcs11.CKM_VENDOR_DEFINED {
+		return uint(mechanism), nil
+	}
+	return 0, errors.Errorf("unsupported digest '%s'", name)
+}
+
+// Digest hashes everything read from r on the token. Input larger than a single chunk is streamed through
+// C_DigestUpdate, so files of any size can be hashed.
+func (p *p11Token) Digest(mechanism uint, r io.Reader) ([]byte, error) {
+	mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}
+
+	info, err := p.ctx.GetMechanismInfo(p.slot, mech)
+	if err != nil || info.Flags&pkcs11.CKF_DIGEST == 0 {
+		return nil, errors.Errorf("token does not support %s for digests", mechToStringAlways(mechanism))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if err := p.ctx.DigestInit(p.session, mech); err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise digest")
+	}
+
+	buf := make([]byte, digestChunkSize)
+	n, err := io.ReadFull(r, buf)
+	switch {
+	case err == io.EOF || err == io.ErrUnexpectedEOF:
+		// Everything fits in one chunk
+		digest, err := p.ctx.Digest(p.session, buf[:n])
+		return digest, errors.WithMessage(err, "failed to digest")
+	case err != nil:
+		return nil, p.abortDigest(err)
+	}
+
+	for n > 0 {
+		if err := p.ctx.DigestUpdate(p.session, buf[:n]); err != nil {
+			return nil, errors.WithMessage(err, "failed to digest")
+		}
+		n, err = io.ReadFull(r, buf)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return nil, p.abortDigest(err)
+		}
+	}
+
+	digest, err := p.ctx.DigestFinal(p.session)
+	return digest, errors.WithMessage(err, "failed to digest")
+}
+
+// abortDigest ends the digest operation on the session after reading the input failed with err.
+func (p *p11Token) abortDigest(err error) error {
+	_, _ = p.ctx.DigestFinal(p.session)
+	return errors.WithMessage(err, "failed to read data")
+}
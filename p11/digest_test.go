@@ -0,0 +1,89 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_Digest(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	small := []byte("firmware")
+	large := bytes.Repeat([]byte{1}, digestChunkSize+10)
+	digest := []byte("digest")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().GetMechanismInfo(slotNumber, gomock.Any()).
+		Return(pkcs11.MechanismInfo{Flags: pkcs11.CKF_DIGEST}, nil).Times(2)
+	mockTokenCtx.EXPECT().DigestInit(session, gomock.Any()).
+		Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism) {
+			require.Equal(t, uint(pkcs11.CKM_SHA256), m[0].Mechanism)
+		}).Return(nil).Times(2)
+
+	// Small input is hashed in one call
+	mockTokenCtx.EXPECT().Digest(session, small).Return(digest, nil)
+
+	// Large input is streamed
+	gomock.InOrder(
+		mockTokenCtx.EXPECT().DigestUpdate(session, large[:digestChunkSize]).Return(nil),
+		mockTokenCtx.EXPECT().DigestUpdate(session, large[digestChunkSize:]).Return(nil),
+		mockTokenCtx.EXPECT().DigestFinal(session).Return(digest, nil),
+	)
+
+	// SHA3-256 is not supported
+	mockTokenCtx.EXPECT().GetMechanismInfo(slotNumber, gomock.Any()).
+		Return(pkcs11.MechanismInfo{Flags: pkcs11.CKF_HW}, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	result, err := p11Token.Digest(pkcs11.CKM_SHA256, bytes.NewReader(small))
+	require.NoError(t, err)
+	require.Equal(t, digest, result)
+
+	result, err = p11Token.Digest(pkcs11.CKM_SHA256, bytes.NewReader(large))
+	require.NoError(t, err)
+	require.Equal(t, digest, result)
+
+	_, err = p11Token.Digest(pkcs11.CKM_SHA3_256, bytes.NewReader(small))
+	require.Error(t, err)
+}
+
+func TestParseDigestMechanism(t *testing.T) {
+	mechanism, err := ParseDigestMechanism("SHA384")
+	require.NoError(t, err)
+	require.Equal(t, uint(pkcs11.CKM_SHA384), mechanism)
+
+	mechanism, err = ParseDigestMechanism("0x80000010")
+	require.NoError(t, err)
+	require.Equal(t, uint(0x80000010), mechanism)
+
+	_, err = ParseDigestMechanism("0x10")
+	require.Error(t, err, "only vendor mechanisms may be given by number")
+
+	_, err = ParseDigestMechanism("md5")
+	require.Error(t, err)
+}
@@ -0,0 +1,126 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"hash"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// eciesKeyLen is the AES-128 key length of the go-ethereum ECIES parameters for secp256k1 and P-256.
+const eciesKeyLen = 16
+
+// DecryptECIES decrypts a message encrypted to the secp256k1 or P-256 key pair with the given label and/or key id by
+// go-ethereum's crypto/ecies, which uses AES-128-CTR and HMAC-SHA-256. Only the ECDH step runs on the token. s1 and
+// s2 are the optional shared information given to ecies.Encrypt.
+func (p *p11Token) DecryptECIES(label string, keyid string, ciphertext []byte, s1 []byte, s2 []byte) ([]byte, error) {
+	private, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	curve, err := p.keyCurve(private)
+	if err != nil {
+		return nil, err
+	}
+	if curve != CurveSecp256k1 && curve != CurveP256 {
+		return nil, errors.Errorf("ECIES is not supported with %s keys", curve)
+	}
+
+	// ciphertext is the sender's ephemeral public key || IV || AES-CTR ciphertext || HMAC
+	size := curve.bits() / 8
+	rLen := 1 + 2*size
+	if len(ciphertext) < rLen+aes.BlockSize+sha256.Size || ciphertext[0] != 0x04 {
+		return nil, errors.New("invalid ECIES message")
+	}
+	if _, err := unmarshalPoint(curve, ciphertext[:rLen]); err != nil {
+		return nil, errors.WithMessage(err, "invalid ECIES message")
+	}
+
+	z, err := p.ecdhSecret(private, ciphertext[:rLen], size)
+	if err != nil {
+		return nil, err
+	}
+
+	k := concatKDF(sha256.New(), z, s1, 2*eciesKeyLen)
+	ke := k[:eciesKeyLen]
+	km := sha256.Sum256(k[eciesKeyLen:])
+
+	em := ciphertext[rLen : len(ciphertext)-sha256.Size]
+	mac := hmac.New(sha256.New, km[:])
+	mac.Write(em)
+	mac.Write(s2)
+	if !hmac.Equal(mac.Sum(nil), ciphertext[len(ciphertext)-sha256.Size:]) {
+		return nil, errors.New("invalid ECIES message")
+	}
+
+	block, err := aes.NewCipher(ke)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext := make([]byte, len(em)-aes.BlockSize)
+	cipher.NewCTR(block, em[:aes.BlockSize]).XORKeyStream(plaintext, em[aes.BlockSize:])
+	return plaintext, nil
+}
+
+// ecdhSecret returns the size byte ECDH shared secret of private and the uncompressed point, which is derived as a
+// temporary extractable session key, read and destroyed.
+func (p *p11Token) ecdhSecret(private pkcs11.ObjectHandle, point []byte, size int) ([]byte, error) {
+	key, err := p.deriveECDH(private, ecdhParams(point, nil, true), []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, size),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, false),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer p.ctx.DestroyObject(p.session, key)
+
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to read shared secret")
+	}
+
+	return attrs[0].Value, nil
+}
+
+// concatKDF is the NIST SP 800-56 concatenation KDF, as used by go-ethereum's crypto/ecies.
+func concatKDF(h hash.Hash, z []byte, s1 []byte, length int) []byte {
+	counter := make([]byte, 4)
+	var k []byte
+	for i := uint32(1); len(k) < length; i++ {
+		binary.BigEndian.PutUint32(counter, i)
+		h.Reset()
+		h.Write(counter)
+		h.Write(z)
+		h.Write(s1)
+		k = h.Sum(k)
+	}
+	return k[:length]
+}
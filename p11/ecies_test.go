@@ -0,0 +1,81 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/rand"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/crypto/ecies"
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_DecryptECIES(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyHandle = pkcs11.ObjectHandle(1)
+	const secretHandle = pkcs11.ObjectHandle(2)
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	message := []byte("mqtt password")
+	ciphertext, err := ecies.Encrypt(rand.Reader, ecies.ImportECDSAPublic(&key.PublicKey), message, nil, nil)
+	require.NoError(t, err)
+
+	// The token computes the shared secret from the ephemeral public key at the start of the message
+	ephemeral, err := crypto.UnmarshalPubkey(ciphertext[:65])
+	require.NoError(t, err)
+	x, _ := key.Curve.ScalarMult(ephemeral.X, ephemeral.Y, key.D.Bytes())
+	shared := x.FillBytes(make([]byte, 32))
+
+	tampered := append([]byte{}, ciphertext...)
+	tampered[len(tampered)-1] ^= 1
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	for i := 0; i < 2; i++ {
+		calls := append(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, keyHandle),
+			expectKeyCurve(mockTokenCtx, session, keyHandle, pkcs11.CKK_EC, secp256k1OID)...)
+		calls = append(calls,
+			mockTokenCtx.EXPECT().DeriveKey(session, gomock.Any(), keyHandle,
+				attributeMatcher{[]*pkcs11.Attribute{
+					pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+					pkcs11.NewAttribute(pkcs11.CKA_TOKEN, false),
+				}}).Return(secretHandle, nil),
+			mockTokenCtx.EXPECT().GetAttributeValue(session, secretHandle,
+				attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)}}).
+				Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, shared)}, nil),
+			mockTokenCtx.EXPECT().DestroyObject(session, secretHandle).Return(nil))
+		gomock.InOrder(calls...)
+	}
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	plaintext, err := p11Token.DecryptECIES("device", "", ciphertext, nil, nil)
+	require.NoError(t, err)
+	require.Equal(t, message, plaintext)
+
+	_, err = p11Token.DecryptECIES("device", "", tampered, nil, nil)
+	require.Error(t, err)
+}
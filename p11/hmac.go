@@ -0,0 +1,118 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/hmac"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// HMACAlgorithm is an HMAC algorithm, named as in JWA (RFC 7518).
+type HMACAlgorithm string
+
+// Supported HMAC algorithms
+const (
+	// HS256 is HMAC with SHA-256
+	HS256 HMACAlgorithm = "HS256"
+	// HS512 is HMAC with SHA-512
+	HS512 HMACAlgorithm = "HS512"
+)
+
+// hmacMechanisms holds the PKCS #11 mechanism for each algorithm.
+var hmacMechanisms = map[HMACAlgorithm]uint{
+	HS256: pkcs11.CKM_SHA256_HMAC,
+	HS512: pkcs11.CKM_SHA512_HMAC,
+}
+
+// ParseHMACAlgorithm returns the algorithm with the given name, HS256 or HS512.
+func ParseHMACAlgorithm(name string) (HMACAlgorithm, error) {
+	alg := HMACAlgorithm(strings.ToUpper(name))
+	if _, ok := hmacMechanisms[alg]; !ok {
+		return "", errors.Errorf("unsupported HMAC algorithm '%s'", name)
+	}
+	return alg, nil
+}
+
+// SignHMAC returns the HMAC of message with the generic secret key with the given label and/or key id.
+func (p *p11Token) SignHMAC(label string, keyid string, alg HMACAlgorithm, message []byte) ([]byte, error) {
+	mechanism, ok := hmacMechanisms[alg]
+	if !ok {
+		return nil, errors.Errorf("unsupported HMAC algorithm '%s'", alg)
+	}
+
+	object, err := p.findKey(pkcs11.CKO_SECRET_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.signRaw(object, mechanism, message)
+}
+
+// VerifyHMAC checks mac is the HMAC of message with the generic secret key with the given label and/or key id.
+func (p *p11Token) VerifyHMAC(label string, keyid string, alg HMACAlgorithm, message []byte, mac []byte) error {
+	expected, err := p.SignHMAC(label, keyid, alg, message)
+	if err != nil {
+		return err
+	}
+
+	if !hmac.Equal(expected, mac) {
+		return errors.New("Not verified")
+	}
+
+	return nil
+}
+
+// GenerateGenericSecretKey creates a new generic secret key of keysize bits, for HMAC, in the token.
+func (p *p11Token) GenerateGenericSecretKey(label string, keysize int) error {
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, keysize/8),
+	}
+
+	_, err := p.ctx.GenerateKey(p.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_GENERIC_SECRET_KEY_GEN, nil)},
+		template)
+
+	return err
+}
+
+// ImportGenericSecret imports a generic secret key, for HMAC, and applies a label.
+func (p *p11Token) ImportGenericSecret(keyBytes []byte, label string) error {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE, keyBytes),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+	}
+
+	_, err := p.ctx.CreateObject(p.session, template)
+	return err
+}
@@ -0,0 +1,89 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_SignVerifyHMAC(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyHandle = pkcs11.ObjectHandle(1)
+	message := []byte("GET /v1/vehicles")
+	mac := []byte("mac")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	for i := 0; i < 3; i++ {
+		calls := append(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, keyHandle),
+			mockTokenCtx.EXPECT().SignInit(session, gomock.Any(), keyHandle).
+				Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _ pkcs11.ObjectHandle) {
+					require.Equal(t, uint(pkcs11.CKM_SHA512_HMAC), m[0].Mechanism)
+				}).Return(nil),
+			mockTokenCtx.EXPECT().Sign(session, message).Return(mac, nil))
+		gomock.InOrder(calls...)
+	}
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	result, err := p11Token.SignHMAC("apikey", "", HS512, message)
+	require.NoError(t, err)
+	require.Equal(t, mac, result)
+
+	require.NoError(t, p11Token.VerifyHMAC("apikey", "", HS512, message, mac))
+	require.Error(t, p11Token.VerifyHMAC("apikey", "", HS512, message, []byte("other")))
+}
+
+func TestP11Token_GenerateGenericSecretKey(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().GenerateKey(session, gomock.Any(), attributeMatcher{[]*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VALUE_LEN, 32),
+	}}).Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _ []*pkcs11.Attribute) {
+		require.Equal(t, uint(pkcs11.CKM_GENERIC_SECRET_KEY_GEN), m[0].Mechanism)
+	}).Return(pkcs11.ObjectHandle(1), nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	require.NoError(t, p11Token.GenerateKeyPair("apikey", "", "", "GENERIC", 256))
+	require.Error(t, p11Token.GenerateKeyPair("apikey", "", "", "GENERIC", 200))
+}
+
+func TestParseHMACAlgorithm(t *testing.T) {
+	alg, err := ParseHMACAlgorithm("hs256")
+	require.NoError(t, err)
+	require.Equal(t, HS256, alg)
+
+	_, err = ParseHMACAlgorithm("HS1")
+	require.Error(t, err)
+}
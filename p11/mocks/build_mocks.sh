@@ -9,6 +9,10 @@ SCRIPT_DIR=$(dirname "$0")
 
 echo "Building mocks..."
 # Add more lines for new files
-mockgen -destination "$SCRIPT_DIR/mock_p11.go" -package mocks -source "$SCRIPT_DIR/../p11.go" TokenCtx
+mockgen -destination "$SCRIPT_DIR/mock_p11.go" -package mocks -source "$SCRIPT_DIR/../tokenctx.go"
+mockgen -destination "$SCRIPT_DIR/mock_token.go" -package mocks -source "$SCRIPT_DIR/../p11.go"
+
+# The p11 tests can't import this package, which imports p11 for the Token mock, so they get their own TokenCtx mock
+mockgen -destination "$SCRIPT_DIR/../mock_tokenctx_test.go" -package p11 -source "$SCRIPT_DIR/../tokenctx.go"
 
 echo "Done"
@@ -1,5 +1,5 @@
 // Code generated by MockGen. DO NOT EDIT.
-// Source: p11.go
+// Source: tokenctx.go
 
 // Package mocks is a generated GoMock package.
 package mocks
@@ -62,6 +62,35 @@ func (mr *MockTokenCtxMockRecorder) CreateObject(sh, temp interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CreateObject", reflect.TypeOf((*MockTokenCtx)(nil).CreateObject), sh, temp)
 }
 
+// Decrypt mocks base method
+func (m *MockTokenCtx) Decrypt(sh pkcs11.SessionHandle, cypher []byte) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Decrypt", sh, cypher)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Decrypt indicates an expected call of Decrypt
+func (mr *MockTokenCtxMockRecorder) Decrypt(sh, cypher interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Decrypt", reflect.TypeOf((*MockTokenCtx)(nil).Decrypt), sh, cypher)
+}
+
+// DecryptInit mocks base method
+func (m_2 *MockTokenCtx) DecryptInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "DecryptInit", sh, m, o)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DecryptInit indicates an expected call of DecryptInit
+func (mr *MockTokenCtxMockRecorder) DecryptInit(sh, m, o interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DecryptInit", reflect.TypeOf((*MockTokenCtx)(nil).DecryptInit), sh, m, o)
+}
+
 // Destroy mocks base method
 func (m *MockTokenCtx) Destroy() {
 	m.ctrl.T.Helper()
@@ -221,6 +250,20 @@ func (mr *MockTokenCtxMockRecorder) GetAttributeValue(sh, o, a interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAttributeValue", reflect.TypeOf((*MockTokenCtx)(nil).GetAttributeValue), sh, o, a)
 }
 
+// SetAttributeValue mocks base method
+func (m *MockTokenCtx) SetAttributeValue(sh pkcs11.SessionHandle, o pkcs11.ObjectHandle, a []*pkcs11.Attribute) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "SetAttributeValue", sh, o, a)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SetAttributeValue indicates an expected call of SetAttributeValue
+func (mr *MockTokenCtxMockRecorder) SetAttributeValue(sh, o, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetAttributeValue", reflect.TypeOf((*MockTokenCtx)(nil).SetAttributeValue), sh, o, a)
+}
+
 // GetSlotList mocks base method
 func (m *MockTokenCtx) GetSlotList(tokenPresent bool) ([]uint, error) {
 	m.ctrl.T.Helper()
@@ -265,6 +308,35 @@ func (mr *MockTokenCtxMockRecorder) Initialize() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Initialize", reflect.TypeOf((*MockTokenCtx)(nil).Initialize))
 }
 
+// SignInit mocks base method
+func (m_2 *MockTokenCtx) SignInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "SignInit", sh, m, o)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// SignInit indicates an expected call of SignInit
+func (mr *MockTokenCtxMockRecorder) SignInit(sh, m, o interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SignInit", reflect.TypeOf((*MockTokenCtx)(nil).SignInit), sh, m, o)
+}
+
+// Sign mocks base method
+func (m *MockTokenCtx) Sign(sh pkcs11.SessionHandle, message []byte) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Sign", sh, message)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Sign indicates an expected call of Sign
+func (mr *MockTokenCtxMockRecorder) Sign(sh, message interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Sign", reflect.TypeOf((*MockTokenCtx)(nil).Sign), sh, message)
+}
+
 // Login mocks base method
 func (m *MockTokenCtx) Login(sh pkcs11.SessionHandle, userType uint, pin string) error {
 	m.ctrl.T.Helper()
@@ -324,110 +396,134 @@ func (mr *MockTokenCtxMockRecorder) GetMechanismInfo(slotID, m interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetMechanismInfo", reflect.TypeOf((*MockTokenCtx)(nil).GetMechanismInfo), slotID, m)
 }
 
-// MockToken is a mock of Token interface
-type MockToken struct {
-	ctrl     *gomock.Controller
-	recorder *MockTokenMockRecorder
+// WrapKey mocks base method
+func (m_2 *MockTokenCtx) WrapKey(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, wrappingkey, key pkcs11.ObjectHandle) ([]byte, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "WrapKey", sh, m, wrappingkey, key)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// MockTokenMockRecorder is the mock recorder for MockToken
-type MockTokenMockRecorder struct {
-	mock *MockToken
+// WrapKey indicates an expected call of WrapKey
+func (mr *MockTokenCtxMockRecorder) WrapKey(sh, m, wrappingkey, key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WrapKey", reflect.TypeOf((*MockTokenCtx)(nil).WrapKey), sh, m, wrappingkey, key)
 }
 
-// NewMockToken creates a new mock instance
-func NewMockToken(ctrl *gomock.Controller) *MockToken {
-	mock := &MockToken{ctrl: ctrl}
-	mock.recorder = &MockTokenMockRecorder{mock}
-	return mock
+// UnwrapKey mocks base method
+func (m_2 *MockTokenCtx) UnwrapKey(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, unwrappingkey pkcs11.ObjectHandle, wrappedkey []byte, a []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "UnwrapKey", sh, m, unwrappingkey, wrappedkey, a)
+	ret0, _ := ret[0].(pkcs11.ObjectHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// EXPECT returns an object that allows the caller to indicate expected use
-func (m *MockToken) EXPECT() *MockTokenMockRecorder {
-	return m.recorder
+// UnwrapKey indicates an expected call of UnwrapKey
+func (mr *MockTokenCtxMockRecorder) UnwrapKey(sh, m, unwrappingkey, wrappedkey, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnwrapKey", reflect.TypeOf((*MockTokenCtx)(nil).UnwrapKey), sh, m, unwrappingkey, wrappedkey, a)
 }
 
-// Checksum mocks base method
-func (m *MockToken) Checksum(keyLabel string) ([]byte, error) {
+// DeriveKey mocks base method
+func (m_2 *MockTokenCtx) DeriveKey(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, basekey pkcs11.ObjectHandle, a []*pkcs11.Attribute) (pkcs11.ObjectHandle, error) {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "DeriveKey", sh, m, basekey, a)
+	ret0, _ := ret[0].(pkcs11.ObjectHandle)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeriveKey indicates an expected call of DeriveKey
+func (mr *MockTokenCtxMockRecorder) DeriveKey(sh, m, basekey, a interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeriveKey", reflect.TypeOf((*MockTokenCtx)(nil).DeriveKey), sh, m, basekey, a)
+}
+
+// GenerateRandom mocks base method
+func (m *MockTokenCtx) GenerateRandom(sh pkcs11.SessionHandle, length int) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Checksum", keyLabel)
+	ret := m.ctrl.Call(m, "GenerateRandom", sh, length)
 	ret0, _ := ret[0].([]byte)
 	ret1, _ := ret[1].(error)
 	return ret0, ret1
 }
 
-// Checksum indicates an expected call of Checksum
-func (mr *MockTokenMockRecorder) Checksum(keyLabel interface{}) *gomock.Call {
+// GenerateRandom indicates an expected call of GenerateRandom
+func (mr *MockTokenCtxMockRecorder) GenerateRandom(sh, length interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Checksum", reflect.TypeOf((*MockToken)(nil).Checksum), keyLabel)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateRandom", reflect.TypeOf((*MockTokenCtx)(nil).GenerateRandom), sh, length)
 }
 
-// ImportKey mocks base method
-func (m *MockToken) ImportKey(keyBytes []byte, label string) error {
+// SeedRandom mocks base method
+func (m *MockTokenCtx) SeedRandom(sh pkcs11.SessionHandle, seed []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "ImportKey", keyBytes, label)
+	ret := m.ctrl.Call(m, "SeedRandom", sh, seed)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// ImportKey indicates an expected call of ImportKey
-func (mr *MockTokenMockRecorder) ImportKey(keyBytes, label interface{}) *gomock.Call {
+// SeedRandom indicates an expected call of SeedRandom
+func (mr *MockTokenCtxMockRecorder) SeedRandom(sh, seed interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ImportKey", reflect.TypeOf((*MockToken)(nil).ImportKey), keyBytes, label)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SeedRandom", reflect.TypeOf((*MockTokenCtx)(nil).SeedRandom), sh, seed)
 }
 
-// DeleteAllExcept mocks base method
-func (m *MockToken) DeleteAllExcept(keyLabels []string) error {
-	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "DeleteAllExcept", keyLabels)
+// DigestInit mocks base method
+func (m_2 *MockTokenCtx) DigestInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism) error {
+	m_2.ctrl.T.Helper()
+	ret := m_2.ctrl.Call(m_2, "DigestInit", sh, m)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// DeleteAllExcept indicates an expected call of DeleteAllExcept
-func (mr *MockTokenMockRecorder) DeleteAllExcept(keyLabels interface{}) *gomock.Call {
+// DigestInit indicates an expected call of DigestInit
+func (mr *MockTokenCtxMockRecorder) DigestInit(sh, m interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteAllExcept", reflect.TypeOf((*MockToken)(nil).DeleteAllExcept), keyLabels)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DigestInit", reflect.TypeOf((*MockTokenCtx)(nil).DigestInit), sh, m)
 }
 
-// PrintObjects mocks base method
-func (m *MockToken) PrintObjects(label *string) error {
+// Digest mocks base method
+func (m *MockTokenCtx) Digest(sh pkcs11.SessionHandle, message []byte) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "PrintObjects", label)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "Digest", sh, message)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// PrintObjects indicates an expected call of PrintObjects
-func (mr *MockTokenMockRecorder) PrintObjects(label interface{}) *gomock.Call {
+// Digest indicates an expected call of Digest
+func (mr *MockTokenCtxMockRecorder) Digest(sh, message interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PrintObjects", reflect.TypeOf((*MockToken)(nil).PrintObjects), label)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Digest", reflect.TypeOf((*MockTokenCtx)(nil).Digest), sh, message)
 }
 
-// GenerateKey mocks base method
-func (m *MockToken) GenerateKey(label, keytype string, keysize int) error {
+// DigestUpdate mocks base method
+func (m *MockTokenCtx) DigestUpdate(sh pkcs11.SessionHandle, message []byte) error {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "GenerateKey", label, keytype, keysize)
+	ret := m.ctrl.Call(m, "DigestUpdate", sh, message)
 	ret0, _ := ret[0].(error)
 	return ret0
 }
 
-// GenerateKey indicates an expected call of GenerateKey
-func (mr *MockTokenMockRecorder) GenerateKey(label, keytype, keysize interface{}) *gomock.Call {
+// DigestUpdate indicates an expected call of DigestUpdate
+func (mr *MockTokenCtxMockRecorder) DigestUpdate(sh, message interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GenerateKey", reflect.TypeOf((*MockToken)(nil).GenerateKey), label, keytype, keysize)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DigestUpdate", reflect.TypeOf((*MockTokenCtx)(nil).DigestUpdate), sh, message)
 }
 
-// Finalise mocks base method
-func (m *MockToken) Finalise() error {
+// DigestFinal mocks base method
+func (m *MockTokenCtx) DigestFinal(sh pkcs11.SessionHandle) ([]byte, error) {
 	m.ctrl.T.Helper()
-	ret := m.ctrl.Call(m, "Finalise")
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret := m.ctrl.Call(m, "DigestFinal", sh)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
-// Finalise indicates an expected call of Finalise
-func (mr *MockTokenMockRecorder) Finalise() *gomock.Call {
+// DigestFinal indicates an expected call of DigestFinal
+func (mr *MockTokenCtxMockRecorder) DigestFinal(sh interface{}) *gomock.Call {
 	mr.mock.ctrl.T.Helper()
-	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Finalise", reflect.TypeOf((*MockToken)(nil).Finalise))
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DigestFinal", reflect.TypeOf((*MockTokenCtx)(nil).DigestFinal), sh)
 }
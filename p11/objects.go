@@ -0,0 +1,190 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"encoding/asn1"
+	"encoding/hex"
+
+	"github.com/miekg/pkcs11"
+)
+
+// HexBytes is a byte slice which is marshalled as hex text.
+type HexBytes []byte
+
+// MarshalText implements encoding.TextMarshaler.
+func (b HexBytes) MarshalText() ([]byte, error) {
+	return []byte(hex.EncodeToString(b)), nil
+}
+
+// ObjectFilter selects the objects returned by ListObjects. Empty fields match any object.
+type ObjectFilter struct {
+	Label string
+	ID    string
+
+	// Class is a CKO_ value, or nil for any class
+	Class *uint
+}
+
+// ObjectInfo describes an object on the token. Fields the object does not have, or which are sensitive, are left
+// empty; Attributes holds the printable values of all its attributes.
+type ObjectInfo struct {
+	Class   string   `json:"class" yaml:"class"`
+	KeyType string   `json:"keyType,omitempty" yaml:"keyType,omitempty"`
+	Label   string   `json:"label" yaml:"label"`
+	ID      HexBytes `json:"id" yaml:"id"`
+
+	Token       bool `json:"token" yaml:"token"`
+	Private     bool `json:"private" yaml:"private"`
+	Sensitive   bool `json:"sensitive" yaml:"sensitive"`
+	Extractable bool `json:"extractable" yaml:"extractable"`
+	Encrypt     bool `json:"encrypt" yaml:"encrypt"`
+	Decrypt     bool `json:"decrypt" yaml:"decrypt"`
+	Sign        bool `json:"sign" yaml:"sign"`
+	Verify      bool `json:"verify" yaml:"verify"`
+	Wrap        bool `json:"wrap" yaml:"wrap"`
+	Unwrap      bool `json:"unwrap" yaml:"unwrap"`
+	Derive      bool `json:"derive" yaml:"derive"`
+
+	// EC keys
+	Curve    string   `json:"curve,omitempty" yaml:"curve,omitempty"`
+	ECParams HexBytes `json:"ecParams,omitempty" yaml:"ecParams,omitempty"`
+	ECPoint  HexBytes `json:"ecPoint,omitempty" yaml:"ecPoint,omitempty"`
+
+	// RSA keys
+	ModulusBits uint `json:"modulusBits,omitempty" yaml:"modulusBits,omitempty"`
+
+	Attributes ObjectAttributes `json:"attributes" yaml:"attributes"`
+}
+
+func (p *p11Token) ListObjects(filter ObjectFilter) ([]ObjectInfo, error) {
+	var template []*pkcs11.Attribute
+	if filter.Class != nil {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_CLASS, *filter.Class))
+	}
+	if filter.Label != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, filter.Label))
+	}
+	if filter.ID != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, filter.ID))
+	}
+
+	objects, err := p.findAllMatching(template)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]ObjectInfo, 0, len(objects))
+	for _, o := range objects {
+		info, err := objectInfo(p.ctx, p.session, o)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, info)
+	}
+
+	return res, nil
+}
+
+// objectInfo reads every attribute in attributeInfo from object.
+func objectInfo(ctx TokenCtx, session pkcs11.SessionHandle, object pkcs11.ObjectHandle) (ObjectInfo, error) {
+	info := ObjectInfo{Attributes: make(ObjectAttributes)}
+	values := make(map[uint][]byte)
+
+	for _, attr := range attributeInfo {
+
+		template := []*pkcs11.Attribute{pkcs11.NewAttribute(attr.aType, nil)}
+		template, err := ctx.GetAttributeValue(session, object, template)
+
+		if p11error, ok := err.(pkcs11.Error); ok {
+			switch p11error {
+			case pkcs11.CKR_ATTRIBUTE_SENSITIVE:
+				info.Attributes[attr.name] = "<sensitive>"
+				continue
+			case pkcs11.CKR_ATTRIBUTE_TYPE_INVALID:
+				continue
+			default:
+				// Do nothing, will pick up below
+				break
+			}
+		}
+
+		if err != nil {
+			// some other error
+			return ObjectInfo{}, err
+		}
+
+		values[attr.aType] = template[0].Value
+		info.Attributes[attr.name] = attr.converter(template[0].Value)
+	}
+
+	if v := values[pkcs11.CKA_CLASS]; len(v) >= 4 {
+		info.Class = classToStr(v)
+	}
+	if v := values[pkcs11.CKA_KEY_TYPE]; len(v) >= 4 {
+		info.KeyType = keyTypeToStr(v)
+	}
+	info.Label = string(values[pkcs11.CKA_LABEL])
+	info.ID = values[pkcs11.CKA_ID]
+
+	for aType, dest := range map[uint]*bool{
+		pkcs11.CKA_TOKEN:       &info.Token,
+		pkcs11.CKA_PRIVATE:     &info.Private,
+		pkcs11.CKA_SENSITIVE:   &info.Sensitive,
+		pkcs11.CKA_EXTRACTABLE: &info.Extractable,
+		pkcs11.CKA_ENCRYPT:     &info.Encrypt,
+		pkcs11.CKA_DECRYPT:     &info.Decrypt,
+		pkcs11.CKA_SIGN:        &info.Sign,
+		pkcs11.CKA_VERIFY:      &info.Verify,
+		pkcs11.CKA_WRAP:        &info.Wrap,
+		pkcs11.CKA_UNWRAP:      &info.Unwrap,
+		pkcs11.CKA_DERIVE:      &info.Derive,
+	} {
+		if v := values[aType]; len(v) > 0 {
+			*dest = v[0] == 1
+		}
+	}
+
+	if v, ok := values[pkcs11.CKA_EC_PARAMS]; ok {
+		info.ECParams = v
+		info.Curve = curveName(v)
+	}
+	if v, ok := values[pkcs11.CKA_EC_POINT]; ok && len(v) > 0 {
+		if info.Curve == string(CurveEd25519) {
+			info.ECPoint = decodeEdwardsPoint(v)
+		} else {
+			info.ECPoint = decodeECPoint(v)
+		}
+	}
+	if v := values[pkcs11.CKA_MODULUS_BITS]; len(v) >= 4 {
+		info.ModulusBits = uint(readAttributeAsULong(v))
+	}
+
+	return info, nil
+}
+
+// curveName returns the name of the named curve in DER encoded EC params, or the OID if it is not supported.
+func curveName(params []byte) string {
+	if curve, err := curveFromParams(params); err == nil {
+		return string(curve)
+	}
+
+	var oid asn1.ObjectIdentifier
+	if _, err := asn1.Unmarshal(params, &oid); err != nil {
+		return ""
+	}
+	return oid.String()
+}
@@ -16,16 +16,24 @@
 package p11
 
 import (
+	gocrypto "crypto"
 	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/asn1"
 	"fmt"
-	"log"
+	"io"
 	"math/big"
 	"sort"
 	"strings"
+	"sync"
 
+	"github.com/ethereum/go-ethereum/accounts"
 	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
 	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
 	"github.com/miekg/pkcs11"
 	"github.com/pkg/errors"
 	"golang.org/x/exp/slices"
@@ -33,32 +41,7 @@ import (
 
 var secp256k1N = crypto.S256().Params().N
 var secp256k1HalfN = new(big.Int).Div(secp256k1N, big.NewInt(2))
-
-// TokenCtx contains the functions we use from github.com/miekg/pkcs11.
-type TokenCtx interface {
-	CloseSession(sh pkcs11.SessionHandle) error
-	CreateObject(sh pkcs11.SessionHandle, temp []*pkcs11.Attribute) (pkcs11.ObjectHandle, error)
-	Destroy()
-	DestroyObject(sh pkcs11.SessionHandle, oh pkcs11.ObjectHandle) error
-	Encrypt(sh pkcs11.SessionHandle, message []byte) ([]byte, error)
-	EncryptInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error
-	Finalize() error
-	FindObjects(sh pkcs11.SessionHandle, max int) ([]pkcs11.ObjectHandle, bool, error)
-	FindObjectsFinal(sh pkcs11.SessionHandle) error
-	FindObjectsInit(sh pkcs11.SessionHandle, temp []*pkcs11.Attribute) error
-	GenerateKey(sh pkcs11.SessionHandle, mech []*pkcs11.Mechanism, temp []*pkcs11.Attribute) (pkcs11.ObjectHandle, error)
-	GenerateKeyPair(sh pkcs11.SessionHandle, mech []*pkcs11.Mechanism, public, private []*pkcs11.Attribute) (pkcs11.ObjectHandle, pkcs11.ObjectHandle, error)
-	GetAttributeValue(sh pkcs11.SessionHandle, o pkcs11.ObjectHandle, a []*pkcs11.Attribute) ([]*pkcs11.Attribute, error)
-	GetSlotList(tokenPresent bool) ([]uint, error)
-	GetTokenInfo(slotID uint) (pkcs11.TokenInfo, error)
-	Initialize() error
-	SignInit(sh pkcs11.SessionHandle, m []*pkcs11.Mechanism, o pkcs11.ObjectHandle) error
-	Sign(sh pkcs11.SessionHandle, message []byte) ([]byte, error)
-	Login(sh pkcs11.SessionHandle, userType uint, pin string) error
-	OpenSession(slotID uint, flags uint) (pkcs11.SessionHandle, error)
-	GetMechanismList(slotID uint) ([]*pkcs11.Mechanism, error)
-	GetMechanismInfo(slotID uint, m []*pkcs11.Mechanism) (pkcs11.MechanismInfo, error)
-}
+var secp256k1OID = asn1.ObjectIdentifier{1, 3, 132, 0, 10}
 
 // Token provides a high level interface to a P11 token.
 type Token interface {
@@ -68,42 +51,157 @@ type Token interface {
 	// ImportKey imports an AES key and applies a label.
 	ImportKey(keyBytes []byte, label string) error
 
-	// DeleteAllExcept deletes all keys on the token except those with a label specified.
-	DeleteAllExcept(keyLabels []string) error
+	// ImportGenericSecret imports a generic secret key, for HMAC, and applies a label.
+	ImportGenericSecret(keyBytes []byte, label string) error
+
+	// Encrypt encrypts plaintext with the AES key with the given label and/or key id, returning an envelope holding
+	// the mode, IV and ciphertext. aad is additional authenticated data for GCM.
+	Encrypt(label string, keyid string, mode CipherMode, plaintext []byte, aad []byte) (envelope []byte, err error)
+
+	// Decrypt decrypts an envelope returned by Encrypt
+	Decrypt(label string, keyid string, envelope []byte, aad []byte) (plaintext []byte, err error)
+
+	// WrapKey returns the extractable secret or private key with the given label and/or key id, encrypted under the
+	// wrapping key.
+	WrapKey(wrappingLabel string, wrappingKeyid string, mechanism WrapMechanism, label string,
+		keyid string) (wrapped []byte, err error)
+
+	// UnwrapKey decrypts a key returned by WrapKey and stores it on the token, extractable only if extractable is set.
+	// keytype is AES, GENERIC, EC, ED25519 or RSA. Private keys get a public key object too; for EC and Ed25519 keys
+	// publicKey gives it.
+	UnwrapKey(unwrappingLabel string, unwrappingKeyid string, mechanism WrapMechanism, wrapped []byte, label string,
+		keyid string, keytype string, publicKey []byte, extractable bool) error
+
+	// DeriveKey stores an AES key of keysize bits on the token, derived with the SHA-256 KDF and sharedInfo from the
+	// ECDH shared secret of the EC private key with the given label and/or key id and a peer public key (PEM, JWK or
+	// hex point). If raw is set, the shared secret is truncated to keysize bits instead.
+	DeriveKey(label string, keyid string, peer []byte, derivedLabel string, keysize int, sharedInfo []byte, raw bool,
+		extractable bool) error
+
+	// EnableDerive allows the EC private key with the given label and/or key id to be used for ECDH, for keys
+	// generated before ECDH support was added.
+	EnableDerive(label string, keyid string) error
+
+	// DecryptECIES decrypts a go-ethereum crypto/ecies message encrypted to the EC key pair with the given label
+	// and/or key id. s1 and s2 are the optional shared information used to encrypt it.
+	DecryptECIES(label string, keyid string, ciphertext []byte, s1 []byte, s2 []byte) (plaintext []byte, err error)
+
+	// SignHMAC returns the HMAC of message with the generic secret key with the given label and/or key id.
+	SignHMAC(label string, keyid string, alg HMACAlgorithm, message []byte) (mac []byte, err error)
+
+	// VerifyHMAC checks mac is the HMAC of message with the generic secret key with the given label and/or key id.
+	VerifyHMAC(label string, keyid string, alg HMACAlgorithm, message []byte, mac []byte) error
+
+	// GenerateRandom returns length bytes from the token's random number generator.
+	GenerateRandom(length int) ([]byte, error)
+
+	// SeedRandom mixes additional seed material into the token's random number generator.
+	SeedRandom(seed []byte) error
+
+	// Digest hashes everything read from r on the token with a digest mechanism from ParseDigestMechanism.
+	Digest(mechanism uint, r io.Reader) ([]byte, error)
+
+	// DeleteAllExcept deletes all keys on the token except those with a label specified, returning the labels of the
+	// keys deleted.
+	DeleteAllExcept(keyLabels []string) (deleted []string, err error)
+
+	// PrintObjects prints the objects in the token matching filter
+	PrintObjects(filter ObjectFilter) error
 
-	// PrintObjects prints all objects in the token if label is nil, otherwise it prints only the objects with that
-	// label
-	PrintObjects(label *string) error
+	// ListObjects returns descriptions of the objects in the token matching filter
+	ListObjects(filter ObjectFilter) ([]ObjectInfo, error)
 
-	// GenerateKey creates a new RSA or AES or EC key of the given size in the token
+	// GenerateKeyPair creates a new RSA, AES, generic secret (GENERIC, for HMAC) or EC key of the given size in the
+	// token. For EC keys algorithm is the curve, one of S256 (secp256k1), P256, P384 or Ed25519.
 	GenerateKeyPair(label string, keyid string, algorithm string, keytype string, keysize int) error
 
-	// GenerateKey creates a new RSA or AES key of the given size in the token
+	// GetPublicKey returns the secp256k1 public key with the given label or ID, both parsed and as the 65-byte
+	// uncompressed point
 	GetPublicKey(label string, keyid string) (publicKey *ecdsa.PublicKey, keyBytes []byte, err error)
 
-	// Sign returns a signature using the in-built curve
+	// Sign returns a signature over hash using the key's curve: a 65-byte R||S||V Ethereum signature for secp256k1,
+	// R||S for P-256 and P-384, and an EdDSA signature of hash itself for Ed25519
 	Sign(label string, keyid string, hash []byte) (signature []byte, err error)
 
-	// Verify checks the provided hash against the provisioned address
+	// Verify checks a signature made by Sign over hash against the public key
 	Verify(label string, keyid string, hash []byte, signature []byte) (err error)
 
+	// SignMessage signs message, first hashing it as is usual for the key's curve: Keccak-256 for secp256k1, SHA-256
+	// for P-256 and SHA-384 for P-384. Ed25519 signs the message itself.
+	SignMessage(label string, keyid string, message []byte) (signature []byte, err error)
+
+	// VerifyMessage checks a signature made by SignMessage
+	VerifyMessage(label string, keyid string, message []byte, signature []byte) (err error)
+
+	// SignRSA signs message with an RSA private key using scheme. The message is hashed on the token.
+	SignRSA(label string, keyid string, scheme RSAScheme, message []byte) (signature []byte, err error)
+
+	// VerifyRSA checks an RSA signature over message, made with scheme, against the public key
+	VerifyRSA(label string, keyid string, scheme RSAScheme, message []byte, signature []byte) (err error)
+
+	// SignPersonal returns a signature over an EIP-191 ("\x19Ethereum Signed Message:\n<len>") prefixed message, as
+	// produced by personal_sign and eth_sign
+	SignPersonal(label string, keyid string, message []byte) (signature []byte, err error)
+
+	// VerifyPersonal checks an EIP-191 signature over message against the provisioned address
+	VerifyPersonal(label string, keyid string, message []byte, signature []byte) (err error)
+
+	// SignTypedData returns a signature over the EIP-712 hash of typedData, as produced by eth_signTypedData_v4
+	SignTypedData(label string, keyid string, typedData apitypes.TypedData) (signature []byte, err error)
+
+	// SignTx signs a legacy, EIP-2930 or EIP-1559 transaction using the latest signer for chainID
+	SignTx(label string, keyid string, tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error)
+
+	// PublicKey returns the *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey with the given label and/or key id
+	PublicKey(label string, keyid string) (publicKey gocrypto.PublicKey, err error)
+
+	// ImportCertificates stores a DER encoded X.509 certificate for the key pair with the given label and/or key id,
+	// giving it its same label and CKA_ID. Any further certificates in chain, which should lead to the root, are stored
+	// with labels "<label>-chain-<n>". Certificates already on the token, by issuer and serial number, are skipped, so
+	// imported may be less than len(chain).
+	ImportCertificates(label string, keyid string, chain [][]byte) (imported int, err error)
+
+	// ListCertificates returns the X.509 certificates on the token, optionally only those with the given label and/or
+	// key id
+	ListCertificates(label string, keyid string) (certificates []CertificateInfo, err error)
+
+	// Signer returns a crypto.Signer for the EC, Ed25519 or RSA private key with the given label and/or key id
+	Signer(label string, keyid string) (signer *KeySigner, err error)
+
+	// ECKeyPairs returns the secp256k1 key pairs on the token, identified by the label and key id of their public key.
+	ECKeyPairs() ([]KeyPair, error)
+
 	// PrintMechanisms prints mechanism info for all supported mechanisms.
 	PrintMechanisms() error
 
+	// ListMechanisms returns mechanism info for all supported mechanisms, sorted by name.
+	ListMechanisms() ([]MechanismDescriptor, error)
+
 	// Finalise closes the library and unloads it.
 	Finalise() error
 }
 
+// KeyPair identifies a secp256k1 key pair on the token.
+type KeyPair struct {
+	Label     string
+	KeyID     string
+	PublicKey *ecdsa.PublicKey
+}
+
 type p11Token struct {
 	ctx     TokenCtx
 	session pkcs11.SessionHandle
 	slot    uint
+
+	// mu serialises the operations which take several calls on the session, such as a search or a sign, so that
+	// the token can be shared between goroutines. Single calls rely on the library's own locking.
+	mu sync.Mutex
 }
 
-func (p *p11Token) DeleteAllExcept(keyLabels []string) error {
+func (p *p11Token) DeleteAllExcept(keyLabels []string) (deleted []string, err error) {
 	objects, err := p.findAllMatching(nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	template := []*pkcs11.Attribute{
@@ -117,14 +215,13 @@ func (p *p11Token) DeleteAllExcept(keyLabels []string) error {
 		if err != nil {
 			if p11error, ok := err.(pkcs11.Error); ok {
 				if p11error == pkcs11.CKR_ATTRIBUTE_TYPE_INVALID {
-					// There is no label associated with this key
-					log.Println("Failed to get label for key, will delete anyway")
+					// There is no label associated with this key, so it is deleted anyway
 					labelExists = false
 				} else {
-					return errors.WithMessage(err, "failed to get label")
+					return deleted, errors.WithMessage(err, "failed to get label")
 				}
 			} else {
-				return errors.WithMessage(err, "failed to get label")
+				return deleted, errors.WithMessage(err, "failed to get label")
 			}
 
 		}
@@ -141,18 +238,20 @@ func (p *p11Token) DeleteAllExcept(keyLabels []string) error {
 		}
 
 		if !keep {
-			if labelExists {
-				log.Printf("Deleting key with label '%s'", string(template[0].Value))
-			}
-
 			err = p.ctx.DestroyObject(p.session, o)
 			if err != nil {
-				return errors.WithMessage(err, "failed to destroy object")
+				return deleted, errors.WithMessage(err, "failed to destroy object")
+			}
+
+			if labelExists {
+				deleted = append(deleted, string(template[0].Value))
+			} else {
+				deleted = append(deleted, "")
 			}
 		}
 	}
 
-	return nil
+	return deleted, nil
 }
 
 func (p *p11Token) Finalise() error {
@@ -165,24 +264,60 @@ func (p *p11Token) Finalise() error {
 	return nil
 }
 
-// NewToken connects to a PKCS#11 token and creates a logged in, ready-to-use interface. Call Finalize() on the
-// return object when finished.
-func NewToken(lib, tokenLabel, pin string) (Token, error) {
+// TokenSpec identifies a token by the fields of its token info. Empty fields match any token.
+type TokenSpec struct {
+	Label        string
+	Serial       string
+	Manufacturer string
+	Model        string
+}
+
+func (s TokenSpec) matches(info pkcs11.TokenInfo) bool {
+	return (s.Label == "" || s.Label == info.Label) &&
+		(s.Serial == "" || s.Serial == info.SerialNumber) &&
+		(s.Manufacturer == "" || s.Manufacturer == info.ManufacturerID) &&
+		(s.Model == "" || s.Model == info.Model)
+}
+
+func (s TokenSpec) String() string {
+	var fields []string
+	for _, f := range []struct{ name, value string }{
+		{"label", s.Label}, {"serial", s.Serial}, {"manufacturer", s.Manufacturer}, {"model", s.Model},
+	} {
+		if f.value != "" {
+			fields = append(fields, fmt.Sprintf("%s=%s", f.name, f.value))
+		}
+	}
+	return strings.Join(fields, ", ")
+}
+
+// NewToken connects to a PKCS#11 token and creates a logged in, ready-to-use interface. The PIN is read from pin
+// and zeroed after login. Call Finalize() on the return object when finished.
+func NewToken(lib, tokenLabel string, pin PINSource) (Token, error) {
+	return NewTokenWithSpec(lib, TokenSpec{Label: tokenLabel}, pin)
+}
+
+// NewTokenWithSpec is like NewToken, but finds the token by any of its label, serial number, manufacturer and model.
+func NewTokenWithSpec(lib string, spec TokenSpec, pin PINSource) (Token, error) {
 	ctx := pkcs11.New(lib)
 	if ctx == nil {
 		return nil, errors.Errorf("failed to load library %s", lib)
 	}
 
-	return newP11Token(ctx, tokenLabel, pin)
+	return newP11TokenWithSpec(ctx, spec, pin)
 }
 
 func newP11Token(ctx TokenCtx, tokenLabel, pin string) (Token, error) {
+	return newP11TokenWithSpec(ctx, TokenSpec{Label: tokenLabel}, PINFromBytes([]byte(pin)))
+}
+
+func newP11TokenWithSpec(ctx TokenCtx, spec TokenSpec, pin PINSource) (Token, error) {
 	err := ctx.Initialize()
 	if err != nil {
 		return nil, err
 	}
 
-	session, slot, err := openUserSession(ctx, tokenLabel, pin)
+	session, slot, err := openUserSession(ctx, spec, pin)
 	return &p11Token{
 		ctx:     ctx,
 		session: session,
@@ -199,6 +334,9 @@ func (p *p11Token) Checksum(keyLabel string) (checksum []byte, err error) {
 
 	mech := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_CBC, make([]byte, 16))}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	err = p.ctx.EncryptInit(p.session, mech, obj)
 	if err != nil {
 		return
@@ -213,6 +351,9 @@ func (p *p11Token) findKeyByLabel(label string) (obj pkcs11.ObjectHandle, err er
 		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
 	}
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	err = p.ctx.FindObjectsInit(p.session, template)
 	if err != nil {
 		return
@@ -232,11 +373,44 @@ func (p *p11Token) findKeyByLabel(label string) (obj pkcs11.ObjectHandle, err er
 	return
 }
 
+// findKey returns the single key of the given class with the label and/or key id provided. An error is returned if
+// there is no match, or more than one.
+func (p *p11Token) findKey(class uint, label string, keyid string) (obj pkcs11.ObjectHandle, err error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, class),
+	}
+	if label != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+	if keyid != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, keyid))
+	}
+
+	objects, err := p.findAllMatching(template)
+	if err != nil {
+		return
+	}
+	if len(objects) > 1 {
+		err = errors.New("More than 1 matching key found, please specify both label and key id")
+		return
+	}
+
+	if len(objects) == 0 {
+		err = errors.New("No matching keys found")
+		return
+	}
+
+	obj = objects[0]
+	return
+}
+
 func (p *p11Token) ImportKey(keyBytes []byte, label string) error {
 	template := []*pkcs11.Attribute{
 		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
 		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
 		pkcs11.NewAttribute(pkcs11.CKA_VALUE, keyBytes),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
 		pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
 		pkcs11.NewAttribute(pkcs11.CKA_WRAP, true),
 		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
@@ -249,8 +423,8 @@ func (p *p11Token) ImportKey(keyBytes []byte, label string) error {
 }
 
 // openP11Session loads the P11 library and creates a logged in session
-func openUserSession(ctx TokenCtx, tokenLabel, pin string) (session pkcs11.SessionHandle, slot uint, err error) {
-	slot, err = findSlotWithToken(ctx, tokenLabel)
+func openUserSession(ctx TokenCtx, spec TokenSpec, pin PINSource) (session pkcs11.SessionHandle, slot uint, err error) {
+	slot, err = findSlotWithToken(ctx, spec)
 	if err != nil {
 		return
 	}
@@ -260,13 +434,20 @@ func openUserSession(ctx TokenCtx, tokenLabel, pin string) (session pkcs11.Sessi
 		return
 	}
 
-	err = ctx.Login(session, pkcs11.CKU_USER, pin)
+	pinBytes, err := pin.PIN()
+	if err != nil {
+		err = errors.WithMessage(err, "failed to get PIN")
+		return
+	}
+	defer zeroBytes(pinBytes)
+
+	err = ctx.Login(session, pkcs11.CKU_USER, unsafeString(pinBytes))
 	return
 }
 
-// findSlotWithToken returns the (first) slot id containing the specific token. If the token is not found an
+// findSlotWithToken returns the (first) slot id containing a token matching spec. If the token is not found an
 // error is returned.
-func findSlotWithToken(ctx TokenCtx, label string) (slot uint, err error) {
+func findSlotWithToken(ctx TokenCtx, spec TokenSpec) (slot uint, err error) {
 	var slots []uint
 	slots, err = ctx.GetSlotList(true)
 	if err != nil {
@@ -280,18 +461,21 @@ func findSlotWithToken(ctx TokenCtx, label string) (slot uint, err error) {
 			return
 		}
 
-		if info.Label == label {
+		if spec.matches(info) {
 			return
 		}
 	}
 
-	err = errors.Errorf("cannot find token %s", label)
+	err = errors.Errorf("cannot find token %s", spec)
 	return
 }
 
 func (p *p11Token) findAllMatching(template []*pkcs11.Attribute) (objects []pkcs11.ObjectHandle, err error) {
 	const batchSize = 20
 
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
 	err = p.ctx.FindObjectsInit(p.session, template)
 	if err != nil {
 		return
@@ -318,92 +502,158 @@ func (p *p11Token) findAllMatching(template []*pkcs11.Attribute) (objects []pkcs
 	return
 }
 
-func (p *p11Token) PrintObjects(label *string) error {
-	var template []*pkcs11.Attribute
-	if label != nil {
-		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, *label))
-	}
-
-	objects, err := p.findAllMatching(template)
+func (p *p11Token) PrintObjects(filter ObjectFilter) error {
+	objects, err := p.ListObjects(filter)
 	if err != nil {
 		return err
 	}
 
 	for i, o := range objects {
-		err := printObject(p.ctx, p.session, o, i+1)
-		if err != nil {
-			return err
-		}
+		printObject(o, i+1)
 	}
 
 	return nil
 }
 
 func (p *p11Token) GetPublicKey(label string, keyid string) (publicKey *ecdsa.PublicKey, keyBytes []byte, err error) {
-	var template []*pkcs11.Attribute
-	template = append(template, pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY))
-	if label != "" {
-		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	object, err := p.findKey(pkcs11.CKO_PUBLIC_KEY, label, keyid)
+	if err != nil {
+		return nil, nil, err
 	}
-	if keyid != "" {
-		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, keyid))
+
+	ecpt := ecPoint(p.ctx, p.session, object)
+
+	pub, err := crypto.UnmarshalPubkey(ecpt)
+	return pub, ecpt, err
+}
+
+func (p *p11Token) ECKeyPairs() ([]KeyPair, error) {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
 	}
 
 	objects, err := p.findAllMatching(template)
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
-	if len(objects) > 1 {
-		return nil, nil, errors.New("More than 1 matching key found, please specify both label and key id")
+
+	marshaledOID, _ := asn1.Marshal(secp256k1OID)
+
+	var keyPairs []KeyPair
+	for _, o := range objects {
+		attrs, err := p.ctx.GetAttributeValue(p.session, o, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+		})
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to get key attributes")
+		}
+
+		if !slices.Equal(attrs[2].Value, marshaledOID) {
+			// Not a secp256k1 key
+			continue
+		}
+
+		pub, err := crypto.UnmarshalPubkey(ecPoint(p.ctx, p.session, o))
+		if err != nil {
+			return nil, errors.WithMessagef(err, "invalid public key with label '%s'", string(attrs[0].Value))
+		}
+
+		keyPairs = append(keyPairs, KeyPair{
+			Label:     string(attrs[0].Value),
+			KeyID:     string(attrs[1].Value),
+			PublicKey: pub,
+		})
 	}
 
-	if len(objects) == 0 {
-		return nil, nil, errors.New("No matching keys found")
+	return keyPairs, nil
+}
+
+func (p *p11Token) Sign(label string, keyid string, hash []byte) (signature []byte, err error) {
+	object, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	curve, err := p.keyCurve(object)
+	if err != nil {
+		return nil, err
 	}
 
-	ecpt := ecPoint(p.ctx, p.session, objects[0])
+	return p.signWithCurve(label, keyid, object, curve, hash)
+}
 
-	pub, err := crypto.UnmarshalPubkey(ecpt)
+func (p *p11Token) SignMessage(label string, keyid string, message []byte) (signature []byte, err error) {
+	object, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
 	if err != nil {
-		log.Println(err)
+		return nil, err
 	}
 
-	return pub, ecpt, err
+	curve, err := p.keyCurve(object)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.signWithCurve(label, keyid, object, curve, curve.digest(message))
 }
 
-func (p *p11Token) Sign(label string, keyid string, hash []byte) (signature []byte, err error) {
-	var template []*pkcs11.Attribute
-	template = append(template, pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY))
-	if label != "" {
-		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+// signWithCurve signs hash with the private key object on curve, as described for Token.Sign.
+func (p *p11Token) signWithCurve(label string, keyid string, object pkcs11.ObjectHandle, curve Curve,
+	hash []byte) ([]byte, error) {
+	switch curve {
+	case CurveSecp256k1:
+		return p.signEthereum(label, keyid, object, hash)
+	case CurveEd25519:
+		return p.signRaw(object, ckmEdDSA, hash)
 	}
 
-	if keyid != "" {
-		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, keyid))
+	// The token would truncate or pad other lengths, which is never what is meant
+	if len(hash) != sha256.Size && len(hash) != sha512.Size384 {
+		return nil, errors.Errorf("%s hash must be 32 or 48 bytes, not %d", curve, len(hash))
 	}
 
-	objects, err := p.findAllMatching(template)
+	sig, err := p.signRaw(object, pkcs11.CKM_ECDSA, hash)
 	if err != nil {
 		return nil, err
 	}
-	if len(objects) > 1 {
-		return nil, errors.New("More than 1 matching key found, please specify both label and key id")
+
+	// The token returns R||S, each the size of the curve order
+	if len(sig) != 2*curve.bits()/8 {
+		return nil, errors.Errorf("unexpected %s signature length %d", curve, len(sig))
 	}
+	return sig, nil
+}
 
-	if len(objects) == 0 {
-		return nil, errors.New("No matching keys found")
+func (p *p11Token) signRaw(object pkcs11.ObjectHandle, mechanism uint, data []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.ctx.SignInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)}, object)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise signing")
 	}
 
-	err = p.ctx.SignInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)}, objects[0])
+	sig, err := p.ctx.Sign(p.session, data)
 	if err != nil {
-		log.Fatalf("Signing Initiation failed (%s)\n", err.Error())
+		return nil, errors.WithMessage(err, "failed to sign")
 	}
 
+	return sig, nil
+}
+
+// signEthereum returns a 65-byte R||S||V signature over hash with the secp256k1 private key object, with S in the
+// lower half of the group and V of 27 or 28.
+func (p *p11Token) signEthereum(label string, keyid string, object pkcs11.ObjectHandle, hash []byte) ([]byte, error) {
 	// Sign Msg
-	sig, err := p.ctx.Sign(p.session, hash)
+	sig, err := p.signRaw(object, pkcs11.CKM_ECDSA, hash)
 	if err != nil {
 		return nil, err
 	}
+	if len(sig) != 64 {
+		return nil, errors.Errorf("unexpected secp256k1 signature length %d", len(sig))
+	}
 	// Get Public Key
 	_, ecpt, err := p.GetPublicKey(label, keyid)
 	if err != nil {
@@ -447,27 +697,90 @@ func (p *p11Token) Sign(label string, keyid string, hash []byte) (signature []by
 }
 
 func (p *p11Token) Verify(label string, keyid string, hash []byte, signature []byte) (err error) {
-	_, ecpt, err := p.GetPublicKey(label, keyid)
+	pub, err := p.PublicKey(label, keyid)
 	if err != nil {
 		return err
 	}
-	recPub, err := crypto.Ecrecover(hash[:], signature)
+
+	return verifyWithKey(pub, hash, signature)
+}
+
+func (p *p11Token) VerifyMessage(label string, keyid string, message []byte, signature []byte) (err error) {
+	object, err := p.findKey(pkcs11.CKO_PUBLIC_KEY, label, keyid)
 	if err != nil {
 		return err
 	}
 
-	if slices.Equal(recPub, ecpt) {
-		log.Println("Verified successfully")
+	curve, err := p.keyCurve(object)
+	if err != nil {
+		return err
+	}
+
+	pub, err := p.publicKey(object)
+	if err != nil {
+		return err
+	}
+
+	return verifyWithKey(pub, curve.digest(message), signature)
+}
+
+// verifyWithKey checks a signature made by Token.Sign over hash.
+func verifyWithKey(pub gocrypto.PublicKey, hash []byte, signature []byte) error {
+	var verified bool
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		if pub.Curve == crypto.S256() {
+			recPub, err := crypto.Ecrecover(hash[:], normaliseRecoveryID(signature))
+			if err != nil {
+				return err
+			}
+			verified = slices.Equal(recPub, crypto.FromECDSAPub(pub))
+			break
+		}
+
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return errors.Errorf("signature must be %d bytes", 2*size)
+		}
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		verified = ecdsa.Verify(pub, hash, r, s)
+	case ed25519.PublicKey:
+		verified = ed25519.Verify(pub, hash, signature)
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+
+	if verified {
 		return nil
 	}
 	return errors.New("Not verified")
 }
 
+func (p *p11Token) SignPersonal(label string, keyid string, message []byte) (signature []byte, err error) {
+	return p.Sign(label, keyid, accounts.TextHash(message))
+}
+
+func (p *p11Token) VerifyPersonal(label string, keyid string, message []byte, signature []byte) (err error) {
+	return p.Verify(label, keyid, accounts.TextHash(message), signature)
+}
+
+func (p *p11Token) SignTx(label string, keyid string, tx *types.Transaction, chainID *big.Int) (signedTx *types.Transaction, err error) {
+	signer := types.LatestSignerForChainID(chainID)
+
+	signature, err := p.Sign(label, keyid, signer.Hash(tx).Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	return tx.WithSignature(signer, normaliseRecoveryID(signature))
+}
+
 func (p *p11Token) GenerateKeyPair(label string, keyid string, algorithm string, keytype string, keysize int) error {
 
 	validRSASize := []int{1024, 2048, 3072, 4096}
 	validAESSize := []int{128, 192, 256}
 	validECSize := []int{128, 192, 256}
+	validGenericSize := []int{128, 256, 384, 512}
 
 	switch keytype {
 	case "RSA":
@@ -482,9 +795,19 @@ func (p *p11Token) GenerateKeyPair(label string, keyid string, algorithm string,
 		} else {
 			return errors.Errorf("Invalid AES key size: %d", keysize)
 		}
+	case "GENERIC":
+		if isValidSize(validGenericSize, keysize) {
+			return p.GenerateGenericSecretKey(label, keysize)
+		} else {
+			return errors.Errorf("Invalid generic secret key size: %d", keysize)
+		}
 	case "EC":
-		if isValidSize(validECSize, keysize) && algorithm == "S256" {
-			return p.GenerateECKey(label, keyid)
+		curve, err := ParseCurve(algorithm)
+		if err != nil {
+			return err
+		}
+		if keysize == curve.bits() || (curve == CurveSecp256k1 && isValidSize(validECSize, keysize)) {
+			return p.generateECKey(label, keyid, curve)
 		} else {
 			return errors.Errorf("Invalid EC key size: %d", keysize)
 		}
@@ -493,7 +816,12 @@ func (p *p11Token) GenerateKeyPair(label string, keyid string, algorithm string,
 	}
 }
 
+// GenerateECKey creates a new secp256k1 key pair in the token.
 func (p *p11Token) GenerateECKey(label string, keyid string) error {
+	return p.generateECKey(label, keyid, CurveSecp256k1)
+}
+
+func (p *p11Token) generateECKey(label string, keyid string, curve Curve) error {
 	var template []*pkcs11.Attribute
 	template = append(template, pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY))
 	if label != "" {
@@ -509,9 +837,9 @@ func (p *p11Token) GenerateECKey(label string, keyid string) error {
 		return errors.New("Key with this label already exists")
 	}
 
-	marshaledOID, _ := asn1.Marshal(asn1.ObjectIdentifier{1, 3, 132, 0, 10})
+	marshaledOID, _ := asn1.Marshal(curveOIDs[curve])
 	publicKeyTemplate := []*pkcs11.Attribute{
-		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, curve.keyType()),
 		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
 		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
 		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
@@ -520,11 +848,13 @@ func (p *p11Token) GenerateECKey(label string, keyid string) error {
 	}
 
 	privateKeyTemplate := []*pkcs11.Attribute{
-		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, curve.keyType()),
 		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
 		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
 		pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true),
 		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		// Edwards keys are for signing only; X25519 is a separate key type
+		pkcs11.NewAttribute(pkcs11.CKA_DERIVE, curve != CurveEd25519),
 
 		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
 
@@ -545,17 +875,16 @@ func (p *p11Token) GenerateECKey(label string, keyid string) error {
 		publicKeyTemplate = append(publicKeyTemplate, pkcs11.NewAttribute(pkcs11.CKA_ID, label))
 	}
 
-	_, _, err = p.ctx.GenerateKeyPair(p.session,
-		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_EC_KEY_PAIR_GEN, nil)},
-		publicKeyTemplate, privateKeyTemplate)
-
-	if err != nil {
-		return err
+	mechanism := uint(pkcs11.CKM_EC_KEY_PAIR_GEN)
+	if curve == CurveEd25519 {
+		mechanism = ckmECEdwardsKeyPairGen
 	}
 
-	log.Printf("Keypair \"%s\" generated on token", label)
+	_, _, err = p.ctx.GenerateKeyPair(p.session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(mechanism, nil)},
+		publicKeyTemplate, privateKeyTemplate)
 
-	return nil
+	return err
 }
 
 func (p *p11Token) GenerateAESKey(label string, keysize int) error {
@@ -563,6 +892,8 @@ func (p *p11Token) GenerateAESKey(label string, keysize int) error {
 	privateKeyTemplate := []*pkcs11.Attribute{
 		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
 		pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_ENCRYPT, true),
+		pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
 		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
 		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
 		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
@@ -573,13 +904,7 @@ func (p *p11Token) GenerateAESKey(label string, keysize int) error {
 		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_GEN, make([]byte, 16))},
 		privateKeyTemplate)
 
-	if err != nil {
-		return err
-	}
-
-	log.Printf("Key \"%s\" generated on token", label)
-
-	return nil
+	return err
 }
 
 func (p *p11Token) GenerateRSAKey(label string, keysize int) error {
@@ -606,19 +931,36 @@ func (p *p11Token) GenerateRSAKey(label string, keysize int) error {
 		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_KEY_PAIR_GEN, nil)},
 		publicKeyTemplate, privateKeyTemplate)
 
+	return err
+}
+
+// MechanismDescriptor describes a mechanism supported by the token.
+type MechanismDescriptor struct {
+	Name       string   `json:"name" yaml:"name"`
+	MinKeySize uint     `json:"minKeySize" yaml:"minKeySize"`
+	MaxKeySize uint     `json:"maxKeySize" yaml:"maxKeySize"`
+	Flags      []string `json:"flags" yaml:"flags"`
+}
+
+func (p *p11Token) PrintMechanisms() error {
+	mechs, err := p.ListMechanisms()
 	if err != nil {
 		return err
 	}
 
-	log.Printf("Keypair \"%s\" generated on token", label)
+	for _, m := range mechs {
+		fmt.Println(m.Name)
+		fmt.Printf("  MinKeySize=%d, MaxKeySize=%d\n", m.MinKeySize, m.MaxKeySize)
+		fmt.Printf("  Flags=%s\n", strings.Join(m.Flags, ", "))
+	}
 
 	return nil
 }
 
-func (p *p11Token) PrintMechanisms() error {
+func (p *p11Token) ListMechanisms() ([]MechanismDescriptor, error) {
 	mechs, err := p.ctx.GetMechanismList(p.slot)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	// Sort alphabetically by name
@@ -626,15 +968,13 @@ func (p *p11Token) PrintMechanisms() error {
 		return strings.Compare(mechToStringAlways(mechs[i].Mechanism), mechToStringAlways(mechs[j].Mechanism)) < 0
 	})
 
+	res := make([]MechanismDescriptor, 0, len(mechs))
 	for _, m := range mechs {
-		fmt.Println(mechToStringAlways(m.Mechanism))
 		info, err := p.ctx.GetMechanismInfo(p.slot, []*pkcs11.Mechanism{m})
 		if err != nil {
-			return err
+			return nil, err
 		}
 
-		fmt.Printf("  MinKeySize=%d, MaxKeySize=%d\n", info.MinKeySize, info.MaxKeySize)
-
 		possibleFlags := map[string]uint{
 			"CKF_HW":                pkcs11.CKF_HW,
 			"CKF_ENCRYPT":           pkcs11.CKF_ENCRYPT,
@@ -651,7 +991,7 @@ func (p *p11Token) PrintMechanisms() error {
 			"CKF_DERIVE":            pkcs11.CKF_DERIVE,
 		}
 
-		var flags []string
+		flags := []string{}
 
 		for name, value := range possibleFlags {
 			if (info.Flags & value) != 0 {
@@ -660,10 +1000,15 @@ func (p *p11Token) PrintMechanisms() error {
 		}
 		sort.Strings(flags)
 
-		fmt.Printf("  Flags=%s\n", strings.Join(flags, ", "))
+		res = append(res, MechanismDescriptor{
+			Name:       mechToStringAlways(m.Mechanism),
+			MinKeySize: info.MinKeySize,
+			MaxKeySize: info.MaxKeySize,
+			Flags:      flags,
+		})
 	}
 
-	return nil
+	return res, nil
 }
 
 func isValidSize(sizes []int, in int) bool {
@@ -680,26 +1025,30 @@ func ecPoint(pkcs11lib TokenCtx, session pkcs11.SessionHandle, key pkcs11.Object
 		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
 	}
 
-	attr, err := pkcs11lib.GetAttributeValue(session, key, template)
-	if err != nil {
-		log.Println("Error getting ec point")
-	}
+	// On error there are no attributes, and the nil point is rejected by the caller
+	attr, _ := pkcs11lib.GetAttributeValue(session, key, template)
 
 	for _, a := range attr {
-		switch {
-
-		case ((len(a.Value) % 2) == 0) && (byte(0x04) == a.Value[0]) && (byte(0x04) == a.Value[len(a.Value)-1]):
-			ecpt = a.Value[0 : len(a.Value)-1] // Trim trailing 0x04
-		case byte(0x04) == a.Value[0] && byte(0x04) == a.Value[2]:
-			ecpt = a.Value[2:len(a.Value)]
-		default:
-			ecpt = a.Value
-		}
+		ecpt = decodeECPoint(a.Value)
 	}
 
 	return ecpt
 }
 
+// decodeECPoint returns the uncompressed point from a CKA_EC_POINT value, removing the DER OCTET STRING wrapping used
+// by most tokens.
+func decodeECPoint(value []byte) []byte {
+	switch {
+
+	case ((len(value) % 2) == 0) && (byte(0x04) == value[0]) && (byte(0x04) == value[len(value)-1]):
+		return value[0 : len(value)-1] // Trim trailing 0x04
+	case byte(0x04) == value[0] && byte(0x04) == value[2]:
+		return value[2:len(value)]
+	default:
+		return value
+	}
+}
+
 func recoverAddress(hash []byte, signature []byte) (addr common.Address, err error) {
 	if signature[64] == 27 || signature[64] == 28 {
 		signature[64] -= 27
@@ -717,6 +1066,17 @@ func recoverAddress(hash []byte, signature []byte) (addr common.Address, err err
 	return
 }
 
+// normaliseRecoveryID returns a copy of signature with a V of 27 or 28 (as returned by Sign and by most wallets)
+// translated to the 0 or 1 expected by crypto.Ecrecover.
+func normaliseRecoveryID(signature []byte) []byte {
+	sig := make([]byte, len(signature))
+	copy(sig, signature)
+	if len(sig) == crypto.SignatureLength && (sig[64] == 27 || sig[64] == 28) {
+		sig[64] -= 27
+	}
+	return sig
+}
+
 func fixLen(b []byte) []byte {
 	i := 0
 	for i < len(b) {
@@ -726,7 +1086,8 @@ func fixLen(b []byte) []byte {
 		i++
 	}
 
+	// Left-pad, so that a value with leading zero bytes keeps its magnitude
 	out := make([]byte, common.HashLength)
-	copy(out, b[i:])
+	copy(out[common.HashLength-len(b[i:]):], b[i:])
 	return out
 }
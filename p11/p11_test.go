@@ -16,11 +16,17 @@
 package p11
 
 import (
+	"crypto/ecdsa"
+	"encoding/asn1"
+	"math/big"
 	"testing"
 
 	"bytes"
 
-	"github.com/DIMO-Network/edge-identity/p11/mocks"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
 	"github.com/golang/mock/gomock"
 	"github.com/miekg/pkcs11"
 	"github.com/stretchr/testify/require"
@@ -32,9 +38,9 @@ const slotNumber uint = 42
 
 // prepMockForLogin creates a mock object that expects the usual method calls up to and including the
 // log in to the token. Callers to this method should immediately defer a call to controller.Finish().
-func prepMockForLogin(t *testing.T) (*gomock.Controller, *mocks.MockTokenCtx, pkcs11.SessionHandle) {
+func prepMockForLogin(t *testing.T) (*gomock.Controller, *MockTokenCtx, pkcs11.SessionHandle) {
 	mockCtrl := gomock.NewController(t)
-	mockTokenCtx := mocks.NewMockTokenCtx(mockCtrl)
+	mockTokenCtx := NewMockTokenCtx(mockCtrl)
 
 	slotList := []uint{slotNumber}
 	session := pkcs11.SessionHandle(64)
@@ -44,7 +50,7 @@ func prepMockForLogin(t *testing.T) (*gomock.Controller, *mocks.MockTokenCtx, pk
 	mockTokenCtx.EXPECT().GetSlotList(true).Return(slotList, nil)
 	mockTokenCtx.EXPECT().GetTokenInfo(slotList[0]).Return(pkcs11.TokenInfo{Label: tokenLabel}, nil)
 	mockTokenCtx.EXPECT().OpenSession(slotList[0], gomock.Any()).Return(session, nil)
-	mockTokenCtx.EXPECT().Login(session, pkcs11.CKU_USER, tokenPIN).Return(nil)
+	mockTokenCtx.EXPECT().Login(session, uint(pkcs11.CKU_USER), tokenPIN).Return(nil)
 
 	return mockCtrl, mockTokenCtx, session
 }
@@ -82,8 +88,9 @@ func TestP11Token_DeleteAllExcept(t *testing.T) {
 	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
 	require.Nil(t, err)
 
-	p11Token.DeleteAllExcept(keyLabels[0:2])
-
+	deleted, err := p11Token.DeleteAllExcept(keyLabels[0:2])
+	require.NoError(t, err)
+	require.Equal(t, []string{"delete1", "delete2", ""}, deleted)
 }
 
 func TestP11Token_Checksum(t *testing.T) {
@@ -212,10 +219,10 @@ func TestP11Token_GenerateKey(t *testing.T) {
 	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
 	require.Nil(t, err)
 
-	err = p11Token.GenerateKey(aesKeyLabel, "AES", 256)
+	err = p11Token.GenerateKeyPair(aesKeyLabel, "", "", "AES", 256)
 	require.Nil(t, err)
 
-	err = p11Token.GenerateKey(rsaKeyLabel, "RSA", 2048)
+	err = p11Token.GenerateKeyPair(rsaKeyLabel, "", "", "RSA", 2048)
 	require.Nil(t, err)
 }
 
@@ -230,7 +237,10 @@ func TestP11Token_PrintObjectsWithLabel(t *testing.T) {
 	///////////////// MOCK EXPECTATIONS /////////////////
 
 	mockTokenCtx.EXPECT().FindObjectsInit(session,
-		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, label)}}).Return(nil)
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, "01"),
+		}}).Return(nil)
 	call1 := mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(handles, false, nil)
 	mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(nil, false, nil).After(call1)
 	mockTokenCtx.EXPECT().FindObjectsFinal(session)
@@ -246,7 +256,7 @@ func TestP11Token_PrintObjectsWithLabel(t *testing.T) {
 	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
 	require.Nil(t, err)
 
-	err = p11Token.PrintObjects(&label)
+	err = p11Token.PrintObjects(ObjectFilter{Label: label, ID: "01"})
 	require.Nil(t, err)
 }
 
@@ -288,3 +298,253 @@ func TestP11Token_PrintMechanisms(t *testing.T) {
 	err = p11Token.PrintMechanisms()
 	require.NoError(t, err)
 }
+
+func TestP11Token_ListMechanisms(t *testing.T) {
+	mockCtrl, mockTokenCtx, _ := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	mechs := []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), pkcs11.NewMechanism(pkcs11.CKM_AES_CBC, nil)}
+
+	mockTokenCtx.EXPECT().GetMechanismList(slotNumber).Return(mechs, nil)
+	mockTokenCtx.EXPECT().GetMechanismInfo(slotNumber, []*pkcs11.Mechanism{mechs[0]}).
+		Return(pkcs11.MechanismInfo{MinKeySize: 256, MaxKeySize: 521, Flags: pkcs11.CKF_SIGN | pkcs11.CKF_VERIFY}, nil)
+	mockTokenCtx.EXPECT().GetMechanismInfo(slotNumber, []*pkcs11.Mechanism{mechs[1]}).
+		Return(pkcs11.MechanismInfo{MinKeySize: 16, MaxKeySize: 32}, nil)
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	descriptors, err := p11Token.ListMechanisms()
+	require.NoError(t, err)
+	require.Equal(t, []MechanismDescriptor{
+		{Name: "CKM_AES_CBC", MinKeySize: 16, MaxKeySize: 32, Flags: []string{}},
+		{Name: "CKM_ECDSA", MinKeySize: 256, MaxKeySize: 521, Flags: []string{"CKF_SIGN", "CKF_VERIFY"}},
+	}, descriptors)
+}
+
+func TestP11Token_ListObjects(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	label := "somelabel"
+	const handle = pkcs11.ObjectHandle(1)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().FindObjectsInit(session,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, label)}}).Return(nil)
+	call1 := mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return([]pkcs11.ObjectHandle{handle}, false, nil)
+	mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(nil, false, nil).After(call1)
+	mockTokenCtx.EXPECT().FindObjectsFinal(session)
+
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_LABEL, label)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_VALUE, nil)}}).
+		Return(nil, pkcs11.Error(pkcs11.CKR_ATTRIBUTE_SENSITIVE))
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_ID, []byte{1})}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, handle, gomock.Any()).AnyTimes().
+		Return(nil, pkcs11.Error(pkcs11.CKR_ATTRIBUTE_TYPE_INVALID))
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	objects, err := p11Token.ListObjects(ObjectFilter{Label: label})
+	require.NoError(t, err)
+	require.Len(t, objects, 1)
+
+	info := objects[0]
+	require.Equal(t, "CKO_SECRET_KEY", info.Class)
+	require.Equal(t, "CKK_AES", info.KeyType)
+	require.Equal(t, label, info.Label)
+	require.Equal(t, HexBytes{1}, info.ID)
+	require.True(t, info.Sensitive)
+	require.False(t, info.Extractable)
+	require.Equal(t, "<sensitive>", info.Attributes["CKA_VALUE"])
+	require.Equal(t, "CKO_SECRET_KEY", info.Attributes["CKA_CLASS"])
+}
+
+func TestCurveName(t *testing.T) {
+	params, err := asn1.Marshal(p256OID)
+	require.NoError(t, err)
+	require.Equal(t, "P-256", curveName(params))
+
+	params, err = asn1.Marshal(asn1.ObjectIdentifier{1, 2, 3})
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3", curveName(params))
+}
+
+// expectFindAllMatching sets up the calls made by findAllMatching for a search on the given object class which returns
+// handles. The calls must be made in the returned order.
+func expectFindAllMatching(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, class uint,
+	handles ...pkcs11.ObjectHandle) []*gomock.Call {
+	return expectFind(mockTokenCtx, session, []*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_CLASS, class)},
+		handles...)
+}
+
+// expectFind sets up the calls made by findAllMatching for a search with a template including required which returns
+// handles. The calls must be made in the returned order.
+func expectFind(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, required []*pkcs11.Attribute,
+	handles ...pkcs11.ObjectHandle) []*gomock.Call {
+	calls := []*gomock.Call{mockTokenCtx.EXPECT().FindObjectsInit(session, attributeMatcher{required}).Return(nil)}
+	if len(handles) > 0 {
+		calls = append(calls, mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(handles, false, nil))
+	}
+
+	// An empty batch ends the search
+	return append(calls,
+		mockTokenCtx.EXPECT().FindObjects(session, gomock.Any()).Return(nil, false, nil),
+		mockTokenCtx.EXPECT().FindObjectsFinal(session).Return(nil))
+}
+
+// ecPointAttribute returns the CKA_EC_POINT attribute a token would report for pub, i.e. the DER encoded
+// uncompressed point.
+func ecPointAttribute(pub []byte) *pkcs11.Attribute {
+	return pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, append([]byte{0x04, byte(len(pub))}, pub...))
+}
+
+// expectKeyCurve sets up the reads of the key type and EC params of key made by keyCurve.
+func expectKeyCurve(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, key pkcs11.ObjectHandle,
+	keyType uint, oid asn1.ObjectIdentifier) []*gomock.Call {
+	params, _ := asn1.Marshal(oid)
+	return []*gomock.Call{
+		mockTokenCtx.EXPECT().GetAttributeValue(session, key,
+			attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).
+			Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType)}, nil),
+		mockTokenCtx.EXPECT().GetAttributeValue(session, key,
+			attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil)}}).
+			Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)}, nil),
+	}
+}
+
+// expectECSign sets up the calls made by Sign for a secp256k1 key pair, with the token producing its signature over
+// hash using key. The expected 65-byte R||S||V signature is returned.
+func expectECSign(t *testing.T, mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, key *ecdsa.PrivateKey,
+	hash []byte) []byte {
+	const privateHandle = pkcs11.ObjectHandle(1)
+	const publicHandle = pkcs11.ObjectHandle(2)
+
+	expected, err := crypto.Sign(hash, key)
+	require.NoError(t, err)
+
+	var calls []*gomock.Call
+	calls = append(calls, expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)...)
+	calls = append(calls, expectKeyCurve(mockTokenCtx, session, privateHandle, pkcs11.CKK_EC, secp256k1OID)...)
+	calls = append(calls,
+		mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)},
+			privateHandle).Return(nil),
+		mockTokenCtx.EXPECT().Sign(session, hash).Return(expected[:64], nil))
+	calls = append(calls, expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)...)
+	gomock.InOrder(calls...)
+
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{ecPointAttribute(crypto.FromECDSAPub(&key.PublicKey))}, nil)
+
+	expected[64] += 27
+	return expected
+}
+
+func TestP11Token_SignPersonal(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	message := []byte("testmessage")
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	expected := expectECSign(t, mockTokenCtx, session, key, accounts.TextHash(message))
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signature, err := p11Token.SignPersonal(keyLabel, "", message)
+	require.NoError(t, err)
+	require.Equal(t, expected, signature)
+}
+
+func TestP11Token_SignPersonalBadSignatureLength(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const privateHandle = pkcs11.ObjectHandle(1)
+	message := []byte("testmessage")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)
+	calls = append(calls, expectKeyCurve(mockTokenCtx, session, privateHandle, pkcs11.CKK_EC, secp256k1OID)...)
+	calls = append(calls,
+		mockTokenCtx.EXPECT().SignInit(session, gomock.Any(), privateHandle).Return(nil),
+		mockTokenCtx.EXPECT().Sign(session, accounts.TextHash(message)).Return(make([]byte, 63), nil))
+	gomock.InOrder(calls...)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	_, err = p11Token.SignPersonal("somekey", "", message)
+	require.Error(t, err)
+}
+
+func TestFixLen(t *testing.T) {
+	require.Equal(t, append(make([]byte, 31), 0x7f), fixLen([]byte{0x7f}))
+	require.Equal(t, append([]byte{0, 0}, bytes.Repeat([]byte{0xaa}, 30)...),
+		fixLen(append([]byte{0, 0}, bytes.Repeat([]byte{0xaa}, 30)...)))
+}
+
+func TestP11Token_SignTx(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	chainID := big.NewInt(137)
+	to := common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB")
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	txs := []*types.Transaction{
+		types.NewTx(&types.LegacyTx{Nonce: 1, GasPrice: big.NewInt(30e9), Gas: 21000, To: &to, Value: big.NewInt(1)}),
+		types.NewTx(&types.AccessListTx{ChainID: chainID, Nonce: 2, GasPrice: big.NewInt(30e9), Gas: 21000, To: &to,
+			Value: big.NewInt(1)}),
+		types.NewTx(&types.DynamicFeeTx{ChainID: chainID, Nonce: 3, GasTipCap: big.NewInt(2e9),
+			GasFeeCap: big.NewInt(40e9), Gas: 21000, To: &to, Value: big.NewInt(1)}),
+	}
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signer := types.LatestSignerForChainID(chainID)
+	for _, tx := range txs {
+		expectECSign(t, mockTokenCtx, session, key, signer.Hash(tx).Bytes())
+
+		signed, err := p11Token.SignTx(keyLabel, "", tx, chainID)
+		require.NoError(t, err)
+
+		sender, err := types.Sender(signer, signed)
+		require.NoError(t, err)
+		require.Equal(t, crypto.PubkeyToAddress(key.PublicKey), sender)
+		require.Equal(t, tx.Type(), signed.Type())
+	}
+}
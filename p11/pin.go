@@ -0,0 +1,139 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"os/exec"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+// PINSource supplies the token user PIN when logging in. The returned bytes are zeroed once Login has been called,
+// so sources must not return memory they need to keep. Copies made outside this package, for example of environment
+// variables or by the PKCS#11 library, cannot be zeroed.
+type PINSource interface {
+	PIN() ([]byte, error)
+}
+
+// PINFunc is a function used as a PINSource.
+type PINFunc func() ([]byte, error)
+
+// PIN implements PINSource.
+func (f PINFunc) PIN() ([]byte, error) {
+	return f()
+}
+
+// PINFromBytes returns a source for pin. The slice itself is zeroed after login.
+func PINFromBytes(pin []byte) PINSource {
+	return PINFunc(func() ([]byte, error) {
+		return pin, nil
+	})
+}
+
+// PINFromFile returns a source which reads the PIN from the first line of a file.
+func PINFromFile(path string) PINSource {
+	return PINFunc(func() ([]byte, error) {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+
+		return readPIN(f)
+	})
+}
+
+// PINFromEnv returns a source which reads the PIN from an environment variable.
+func PINFromEnv(name string) PINSource {
+	return PINFunc(func() ([]byte, error) {
+		pin, ok := os.LookupEnv(name)
+		if !ok {
+			return nil, errors.Errorf("environment variable %s is not set", name)
+		}
+		return []byte(pin), nil
+	})
+}
+
+// PINFromFD returns a source which reads the PIN from the first line of an open file descriptor, such as a pipe set
+// up by the parent process. The descriptor is closed afterwards.
+func PINFromFD(fd int) PINSource {
+	return PINFunc(func() ([]byte, error) {
+		f := os.NewFile(uintptr(fd), "pin")
+		if f == nil {
+			return nil, errors.Errorf("invalid file descriptor %d", fd)
+		}
+		defer f.Close()
+
+		return readPIN(f)
+	})
+}
+
+// PINFromCommand returns a source which runs a command and reads the PIN from the first line of its standard output.
+// The command's standard error is passed through.
+func PINFromCommand(name string, args ...string) PINSource {
+	return PINFunc(func() ([]byte, error) {
+		cmd := exec.Command(name, args...)
+		cmd.Stderr = os.Stderr
+
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, errors.WithMessage(err, "PIN command failed")
+		}
+
+		pin, err := readPIN(bytes.NewReader(out))
+		zeroBytes(out)
+		return pin, err
+	})
+}
+
+// readPIN returns the first line of r, without the line ending.
+func readPIN(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		zeroBytes(data)
+		return nil, err
+	}
+
+	end := bytes.IndexAny(data, "\r\n")
+	if end < 0 {
+		end = len(data)
+	}
+
+	pin := make([]byte, end)
+	copy(pin, data)
+	zeroBytes(data)
+
+	return pin, nil
+}
+
+// unsafeString returns b as a string without copying, so that zeroing b also clears the string. The string must not
+// be used after b is modified.
+func unsafeString(b []byte) string {
+	if len(b) == 0 {
+		return ""
+	}
+	return *(*string)(unsafe.Pointer(&b))
+}
+
+func zeroBytes(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
@@ -0,0 +1,74 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPINSources(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pin")
+	require.NoError(t, os.WriteFile(path, []byte(tokenPIN+"\n"), 0600))
+
+	t.Setenv("EDGE_IDENTITY_TEST_PIN", tokenPIN)
+
+	for name, source := range map[string]PINSource{
+		"bytes":   PINFromBytes([]byte(tokenPIN)),
+		"file":    PINFromFile(path),
+		"env":     PINFromEnv("EDGE_IDENTITY_TEST_PIN"),
+		"command": PINFromCommand("/bin/sh", "-c", "echo "+tokenPIN),
+	} {
+		pin, err := source.PIN()
+		require.NoError(t, err, name)
+		require.Equal(t, tokenPIN, string(pin), name)
+	}
+
+	_, err := PINFromEnv("EDGE_IDENTITY_TEST_UNSET").PIN()
+	require.Error(t, err)
+
+	_, err = PINFromCommand("/bin/sh", "-c", "exit 1").PIN()
+	require.Error(t, err)
+}
+
+func TestPINFromFD(t *testing.T) {
+	r, w, err := os.Pipe()
+	require.NoError(t, err)
+
+	_, err = w.WriteString(tokenPIN + "\r\nignored\n")
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	pin, err := PINFromFD(int(r.Fd())).PIN()
+	require.NoError(t, err)
+	require.Equal(t, tokenPIN, string(pin))
+}
+
+func TestNewToken_ZeroesPIN(t *testing.T) {
+	mockCtrl, mockTokenCtx, _ := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	pin := []byte(tokenPIN)
+
+	p11Token, err := newP11TokenWithSpec(mockTokenCtx, TokenSpec{Label: tokenLabel}, PINFromBytes(pin))
+	require.NoError(t, err)
+	require.NotNil(t, p11Token)
+
+	require.Equal(t, make([]byte, len(tokenPIN)), pin)
+}
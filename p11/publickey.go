@@ -0,0 +1,178 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/ssh"
+)
+
+// PublicKeyFormat is an encoding supported by ExportPublicKey.
+type PublicKeyFormat string
+
+// Public key formats
+const (
+	// PublicKeyPEM is a PEM encoded SubjectPublicKeyInfo
+	PublicKeyPEM PublicKeyFormat = "pem"
+	// PublicKeyDER is a DER encoded SubjectPublicKeyInfo
+	PublicKeyDER PublicKeyFormat = "der"
+	// PublicKeyJWK is a JSON Web Key (RFC 7517), using crv "secp256k1" for secp256k1 keys
+	PublicKeyJWK PublicKeyFormat = "jwk"
+	// PublicKeySSH is an OpenSSH authorized_keys line. secp256k1 keys are not supported by OpenSSH.
+	PublicKeySSH PublicKeyFormat = "ssh"
+	// PublicKeyHex is the hex encoded uncompressed EC point, or the Ed25519 public key
+	PublicKeyHex PublicKeyFormat = "hex"
+	// PublicKeyHexCompressed is the hex encoded compressed EC point, or the Ed25519 public key
+	PublicKeyHexCompressed PublicKeyFormat = "hex-compressed"
+)
+
+// oidPublicKeyECDSA is id-ecPublicKey from RFC 5480.
+var oidPublicKeyECDSA = asn1.ObjectIdentifier{1, 2, 840, 10045, 2, 1}
+
+// jwk holds the public members of a JSON Web Key.
+type jwk struct {
+	Kty string `json:"kty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+// ExportPublicKey encodes an *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey, as returned by Token.PublicKey,
+// in format.
+func ExportPublicKey(pub crypto.PublicKey, format PublicKeyFormat) ([]byte, error) {
+	switch format {
+	case PublicKeyPEM:
+		der, err := marshalPublicKey(pub)
+		if err != nil {
+			return nil, err
+		}
+		return pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), nil
+	case PublicKeyDER:
+		return marshalPublicKey(pub)
+	case PublicKeyJWK:
+		return marshalJWK(pub)
+	case PublicKeySSH:
+		if isSecp256k1(pub) {
+			return nil, errors.New("OpenSSH does not support secp256k1 keys")
+		}
+		sshKey, err := ssh.NewPublicKey(pub)
+		if err != nil {
+			return nil, errors.WithMessage(err, "failed to convert to an SSH key")
+		}
+		return ssh.MarshalAuthorizedKey(sshKey), nil
+	case PublicKeyHex, PublicKeyHexCompressed:
+		if edPub, ok := pub.(ed25519.PublicKey); ok {
+			// Ed25519 keys are always compressed
+			return []byte(hex.EncodeToString(edPub)), nil
+		}
+		ecPub, ok := pub.(*ecdsa.PublicKey)
+		if !ok {
+			return nil, errors.Errorf("format %s is only supported for EC keys", format)
+		}
+		var point []byte
+		if format == PublicKeyHex {
+			point = elliptic.Marshal(ecPub.Curve, ecPub.X, ecPub.Y)
+		} else {
+			point = compressPoint(ecPub)
+		}
+		return []byte(hex.EncodeToString(point)), nil
+	default:
+		return nil, errors.Errorf("unknown public key format '%s'", format)
+	}
+}
+
+// marshalPublicKey returns the DER encoded SubjectPublicKeyInfo of pub. x509.MarshalPKIXPublicKey does not know
+// secp256k1, so that curve is encoded here.
+func marshalPublicKey(pub crypto.PublicKey) ([]byte, error) {
+	if !isSecp256k1(pub) {
+		der, err := x509.MarshalPKIXPublicKey(pub)
+		return der, errors.WithMessage(err, "failed to marshal public key")
+	}
+
+	ecPub := pub.(*ecdsa.PublicKey)
+	params, err := asn1.Marshal(secp256k1OID)
+	if err != nil {
+		return nil, err
+	}
+
+	point := elliptic.Marshal(ecPub.Curve, ecPub.X, ecPub.Y)
+	return asn1.Marshal(struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPublicKeyECDSA, Parameters: asn1.RawValue{FullBytes: params}},
+		PublicKey: asn1.BitString{Bytes: point, BitLength: 8 * len(point)},
+	})
+}
+
+func marshalJWK(pub crypto.PublicKey) ([]byte, error) {
+	b64 := base64.RawURLEncoding.EncodeToString
+
+	var key jwk
+	switch pub := pub.(type) {
+	case *ecdsa.PublicKey:
+		key.Kty = "EC"
+		if isSecp256k1(pub) {
+			key.Crv = "secp256k1"
+		} else {
+			key.Crv = pub.Curve.Params().Name
+		}
+		size := (pub.Curve.Params().BitSize + 7) / 8
+		key.X = b64(pub.X.FillBytes(make([]byte, size)))
+		key.Y = b64(pub.Y.FillBytes(make([]byte, size)))
+	case ed25519.PublicKey:
+		key.Kty = "OKP"
+		key.Crv = "Ed25519"
+		key.X = b64(pub)
+	case *rsa.PublicKey:
+		key.Kty = "RSA"
+		key.N = b64(pub.N.Bytes())
+		key.E = b64(big.NewInt(int64(pub.E)).Bytes())
+	default:
+		return nil, errors.Errorf("unsupported public key type %T", pub)
+	}
+
+	return json.Marshal(key)
+}
+
+func compressPoint(pub *ecdsa.PublicKey) []byte {
+	if isSecp256k1(pub) {
+		return ethcrypto.CompressPubkey(pub)
+	}
+	return elliptic.MarshalCompressed(pub.Curve, pub.X, pub.Y)
+}
+
+func isSecp256k1(pub crypto.PublicKey) bool {
+	ecPub, ok := pub.(*ecdsa.PublicKey)
+	return ok && ecPub.Curve == ethcrypto.S256()
+}
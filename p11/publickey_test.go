@@ -0,0 +1,153 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+func TestExportPublicKey_PEM(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(&key.PublicKey, PublicKeyPEM)
+	require.NoError(t, err)
+
+	block, _ := pem.Decode(out)
+	require.NotNil(t, block)
+	require.Equal(t, "PUBLIC KEY", block.Type)
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	require.NoError(t, err)
+	require.True(t, key.PublicKey.Equal(pub))
+}
+
+func TestExportPublicKey_DERSecp256k1(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(&key.PublicKey, PublicKeyDER)
+	require.NoError(t, err)
+
+	var spki struct {
+		Algorithm struct {
+			Algorithm  asn1.ObjectIdentifier
+			Parameters asn1.ObjectIdentifier
+		}
+		PublicKey asn1.BitString
+	}
+	rest, err := asn1.Unmarshal(out, &spki)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.True(t, spki.Algorithm.Algorithm.Equal(oidPublicKeyECDSA))
+	require.True(t, spki.Algorithm.Parameters.Equal(secp256k1OID))
+	require.Equal(t, ethcrypto.FromECDSAPub(&key.PublicKey), spki.PublicKey.Bytes)
+}
+
+func TestExportPublicKey_JWK(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(&key.PublicKey, PublicKeyJWK)
+	require.NoError(t, err)
+
+	var decoded jwk
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, "EC", decoded.Kty)
+	require.Equal(t, "secp256k1", decoded.Crv)
+	require.Len(t, decoded.X, 43)
+	require.Len(t, decoded.Y, 43)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	out, err = ExportPublicKey(&rsaKey.PublicKey, PublicKeyJWK)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, "RSA", decoded.Kty)
+	require.Equal(t, "AQAB", decoded.E)
+}
+
+func TestExportPublicKey_SSH(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(&key.PublicKey, PublicKeySSH)
+	require.NoError(t, err)
+
+	sshKey, _, _, _, err := ssh.ParseAuthorizedKey(out)
+	require.NoError(t, err)
+	require.Equal(t, ssh.KeyAlgoECDSA384, sshKey.Type())
+
+	k1Key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	_, err = ExportPublicKey(&k1Key.PublicKey, PublicKeySSH)
+	require.Error(t, err)
+}
+
+func TestExportPublicKey_Hex(t *testing.T) {
+	key, err := ethcrypto.GenerateKey()
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(&key.PublicKey, PublicKeyHex)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(ethcrypto.FromECDSAPub(&key.PublicKey)), string(out))
+
+	out, err = ExportPublicKey(&key.PublicKey, PublicKeyHexCompressed)
+	require.NoError(t, err)
+	require.Equal(t, hex.EncodeToString(ethcrypto.CompressPubkey(&key.PublicKey)), string(out))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+
+	_, err = ExportPublicKey(&rsaKey.PublicKey, PublicKeyHex)
+	require.Error(t, err)
+}
+
+func TestExportPublicKey_Ed25519(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	out, err := ExportPublicKey(pub, PublicKeyJWK)
+	require.NoError(t, err)
+
+	var decoded jwk
+	require.NoError(t, json.Unmarshal(out, &decoded))
+	require.Equal(t, "OKP", decoded.Kty)
+	require.Equal(t, "Ed25519", decoded.Crv)
+
+	out, err = ExportPublicKey(pub, PublicKeyDER)
+	require.NoError(t, err)
+
+	parsed, err := x509.ParsePKIXPublicKey(out)
+	require.NoError(t, err)
+	require.Equal(t, pub, parsed)
+}
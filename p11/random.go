@@ -0,0 +1,46 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"github.com/pkg/errors"
+)
+
+// GenerateRandom returns length bytes from the token's random number generator.
+func (p *p11Token) GenerateRandom(length int) ([]byte, error) {
+	if length <= 0 {
+		return nil, errors.Errorf("invalid length %d", length)
+	}
+
+	random, err := p.ctx.GenerateRandom(p.session, length)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to generate random data")
+	}
+	if len(random) != length {
+		return nil, errors.Errorf("token returned %d random bytes, not %d", len(random), length)
+	}
+
+	return random, nil
+}
+
+// SeedRandom mixes seed into the state of the token's random number generator. Many tokens do not support seeding.
+func (p *p11Token) SeedRandom(seed []byte) error {
+	if len(seed) == 0 {
+		return errors.New("empty seed")
+	}
+
+	return errors.WithMessage(p.ctx.SeedRandom(p.session, seed), "failed to seed random number generator")
+}
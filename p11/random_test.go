@@ -0,0 +1,53 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_GenerateRandom(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	random := []byte{1, 2, 3, 4}
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().SeedRandom(session, []byte{9}).Return(nil)
+	mockTokenCtx.EXPECT().GenerateRandom(session, 4).Return(random, nil)
+	mockTokenCtx.EXPECT().GenerateRandom(session, 8).Return(random, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	require.NoError(t, p11Token.SeedRandom([]byte{9}))
+	require.Error(t, p11Token.SeedRandom(nil))
+
+	result, err := p11Token.GenerateRandom(4)
+	require.NoError(t, err)
+	require.Equal(t, random, result)
+
+	_, err = p11Token.GenerateRandom(8)
+	require.Error(t, err, "short reads are reported")
+
+	_, err = p11Token.GenerateRandom(0)
+	require.Error(t, err)
+}
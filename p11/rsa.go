@@ -0,0 +1,132 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/rsa"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// RSAScheme is an RSA signature scheme, named as in JWA (RFC 7518).
+type RSAScheme string
+
+// Supported RSA signature schemes
+const (
+	// RS256 is RSASSA-PKCS1-v1_5 with SHA-256
+	RS256 RSAScheme = "RS256"
+	// RS384 is RSASSA-PKCS1-v1_5 with SHA-384
+	RS384 RSAScheme = "RS384"
+	// RS512 is RSASSA-PKCS1-v1_5 with SHA-512
+	RS512 RSAScheme = "RS512"
+	// PS256 is RSASSA-PSS with SHA-256, MGF1 with SHA-256 and a 32 byte salt
+	PS256 RSAScheme = "PS256"
+	// PS384 is RSASSA-PSS with SHA-384, MGF1 with SHA-384 and a 48 byte salt
+	PS384 RSAScheme = "PS384"
+	// PS512 is RSASSA-PSS with SHA-512, MGF1 with SHA-512 and a 64 byte salt
+	PS512 RSAScheme = "PS512"
+)
+
+// rsaSchemes holds the hash and PKCS #11 mechanism, which hashes on the token, for each scheme.
+var rsaSchemes = map[RSAScheme]struct {
+	hash      crypto.Hash
+	mechanism uint
+	pss       bool
+}{
+	RS256: {crypto.SHA256, pkcs11.CKM_SHA256_RSA_PKCS, false},
+	RS384: {crypto.SHA384, pkcs11.CKM_SHA384_RSA_PKCS, false},
+	RS512: {crypto.SHA512, pkcs11.CKM_SHA512_RSA_PKCS, false},
+	PS256: {crypto.SHA256, pkcs11.CKM_SHA256_RSA_PKCS_PSS, true},
+	PS384: {crypto.SHA384, pkcs11.CKM_SHA384_RSA_PKCS_PSS, true},
+	PS512: {crypto.SHA512, pkcs11.CKM_SHA512_RSA_PKCS_PSS, true},
+}
+
+// ParseRSAScheme returns the scheme with the given name, such as RS256 or PS256.
+func ParseRSAScheme(name string) (RSAScheme, error) {
+	scheme := RSAScheme(strings.ToUpper(name))
+	if _, ok := rsaSchemes[scheme]; !ok {
+		return "", errors.Errorf("unsupported RSA scheme '%s'", name)
+	}
+	return scheme, nil
+}
+
+func (p *p11Token) SignRSA(label string, keyid string, scheme RSAScheme, message []byte) ([]byte, error) {
+	s, ok := rsaSchemes[scheme]
+	if !ok {
+		return nil, errors.Errorf("unsupported RSA scheme '%s'", scheme)
+	}
+
+	object, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	var params []byte
+	if s.pss {
+		mechs := rsaPSSHashes[s.hash]
+		params = pkcs11.NewPSSParams(mechs[0], mechs[1], uint(s.hash.Size()))
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err = p.ctx.SignInit(p.session, []*pkcs11.Mechanism{pkcs11.NewMechanism(s.mechanism, params)}, object)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise signing")
+	}
+
+	sig, err := p.ctx.Sign(p.session, message)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign")
+	}
+
+	return sig, nil
+}
+
+func (p *p11Token) VerifyRSA(label string, keyid string, scheme RSAScheme, message []byte, signature []byte) error {
+	s, ok := rsaSchemes[scheme]
+	if !ok {
+		return errors.Errorf("unsupported RSA scheme '%s'", scheme)
+	}
+
+	pub, err := p.PublicKey(label, keyid)
+	if err != nil {
+		return err
+	}
+
+	rsaPub, ok := pub.(*rsa.PublicKey)
+	if !ok {
+		return errors.New("not an RSA key")
+	}
+
+	h := s.hash.New()
+	h.Write(message)
+	digest := h.Sum(nil)
+
+	if s.pss {
+		err = rsa.VerifyPSS(rsaPub, s.hash, digest, signature, &rsa.PSSOptions{SaltLength: s.hash.Size()})
+	} else {
+		err = rsa.VerifyPKCS1v15(rsaPub, s.hash, digest, signature)
+	}
+	if err != nil {
+		return errors.New("Not verified")
+	}
+
+	return nil
+}
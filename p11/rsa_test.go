@@ -0,0 +1,85 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"math/big"
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRSAScheme(t *testing.T) {
+	scheme, err := ParseRSAScheme("ps256")
+	require.NoError(t, err)
+	require.Equal(t, PS256, scheme)
+
+	_, err = ParseRSAScheme("ES256")
+	require.Error(t, err)
+}
+
+func TestP11Token_SignRSA_PSS(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	const privateHandle = pkcs11.ObjectHandle(1)
+	const publicHandle = pkcs11.ObjectHandle(2)
+	message := []byte("testmessage")
+	digest := sha256.Sum256(message)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	expected, err := rsa.SignPSS(rand.Reader, key, crypto.SHA256, digest[:], &rsa.PSSOptions{SaltLength: 32})
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, privateHandle)...)
+	params := pkcs11.NewPSSParams(pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256, 32)
+	mockTokenCtx.EXPECT().SignInit(session,
+		[]*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_SHA256_RSA_PKCS_PSS, params)}, privateHandle).Return(nil)
+	mockTokenCtx.EXPECT().Sign(session, message).Return(expected, nil)
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, publicHandle)...)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, publicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil)}}).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()),
+		}, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signature, err := p11Token.SignRSA(keyLabel, "", PS256, message)
+	require.NoError(t, err)
+	require.Equal(t, expected, signature)
+
+	require.NoError(t, p11Token.VerifyRSA(keyLabel, "", PS256, message, signature))
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"io"
+	"math/big"
+
+	ethcrypto "github.com/ethereum/go-ethereum/crypto"
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+var (
+	p256OID = asn1.ObjectIdentifier{1, 2, 840, 10045, 3, 1, 7}
+	p384OID = asn1.ObjectIdentifier{1, 3, 132, 0, 34}
+)
+
+// rsaHashPrefixes are the DER encoded DigestInfo prefixes used for PKCS #1 v1.5 signatures, as in crypto/rsa.
+var rsaHashPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA1:   {0x30, 0x21, 0x30, 0x09, 0x06, 0x05, 0x2b, 0x0e, 0x03, 0x02, 0x1a, 0x05, 0x00, 0x04, 0x14},
+	crypto.SHA224: {0x30, 0x2d, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x04, 0x05, 0x00, 0x04, 0x1c},
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// rsaPSSHashes maps a hash to the PKCS#11 hash and MGF mechanisms used in CK_RSA_PKCS_PSS_PARAMS.
+var rsaPSSHashes = map[crypto.Hash][2]uint{
+	crypto.SHA1:   {pkcs11.CKM_SHA_1, pkcs11.CKG_MGF1_SHA1},
+	crypto.SHA224: {pkcs11.CKM_SHA224, pkcs11.CKG_MGF1_SHA224},
+	crypto.SHA256: {pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256},
+	crypto.SHA384: {pkcs11.CKM_SHA384, pkcs11.CKG_MGF1_SHA384},
+	crypto.SHA512: {pkcs11.CKM_SHA512, pkcs11.CKG_MGF1_SHA512},
+}
+
+// KeySigner is a crypto.Signer for an EC, Ed25519 or RSA private key on the token, for use with crypto/tls,
+// crypto/x509 and similar. EC signatures are ASN.1 DER encoded; use Token.Sign for Ethereum R||S||V signatures.
+type KeySigner struct {
+	token     *p11Token
+	key       pkcs11.ObjectHandle
+	publicKey crypto.PublicKey
+}
+
+var _ crypto.Signer = (*KeySigner)(nil)
+
+func (p *p11Token) Signer(label string, keyid string) (*KeySigner, error) {
+	privateKey, err := p.findKey(pkcs11.CKO_PRIVATE_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := p.ctx.GetAttributeValue(p.session, privateKey, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get key type")
+	}
+
+	publicKey, err := p.findKey(pkcs11.CKO_PUBLIC_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	pub, err := p.publicKeyOfType(publicKey, uint(readAttributeAsULong(attrs[0].Value)))
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySigner{
+		token:     p,
+		key:       privateKey,
+		publicKey: pub,
+	}, nil
+}
+
+// Public returns the *ecdsa.PublicKey, ed25519.PublicKey or *rsa.PublicKey of the key pair.
+func (s *KeySigner) Public() crypto.PublicKey {
+	return s.publicKey
+}
+
+// Sign signs digest on the token. For RSA keys, opts may be an *rsa.PSSOptions to select PSS, otherwise PKCS #1 v1.5
+// is used. Ed25519 keys sign the whole message, passed as digest with a zero hash, as ed25519.PrivateKey does. The
+// random source is ignored as the token has its own.
+func (s *KeySigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+	if hash != 0 && len(digest) != hash.Size() {
+		return nil, errors.Errorf("digest length %d does not match hash %s", len(digest), hash)
+	}
+
+	switch s.publicKey.(type) {
+	case *ecdsa.PublicKey:
+		return s.signECDSA(digest)
+	case *rsa.PublicKey:
+		return s.signRSA(digest, opts)
+	case ed25519.PublicKey:
+		if hash != 0 {
+			return nil, errors.New("Ed25519 keys sign the message itself, not a digest")
+		}
+		return s.sign(pkcs11.NewMechanism(ckmEdDSA, nil), digest)
+	default:
+		return nil, errors.New("unsupported key type")
+	}
+}
+
+func (s *KeySigner) signECDSA(digest []byte) ([]byte, error) {
+	sig, err := s.sign(pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil), digest)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token returns R||S, each the size of the curve order
+	n := len(sig) / 2
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{new(big.Int).SetBytes(sig[:n]), new(big.Int).SetBytes(sig[n:])})
+}
+
+func (s *KeySigner) signRSA(digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	hash := opts.HashFunc()
+
+	if pssOpts, ok := opts.(*rsa.PSSOptions); ok {
+		mechs, ok := rsaPSSHashes[hash]
+		if !ok {
+			return nil, errors.Errorf("unsupported hash %s for RSA-PSS", hash)
+		}
+
+		saltLength := pssOpts.SaltLength
+		if saltLength == rsa.PSSSaltLengthAuto || saltLength == rsa.PSSSaltLengthEqualsHash {
+			saltLength = hash.Size()
+		}
+
+		params := pkcs11.NewPSSParams(mechs[0], mechs[1], uint(saltLength))
+		return s.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_PSS, params), digest)
+	}
+
+	// With no hash the digest is signed as is, as rsa.SignPKCS1v15 does
+	var prefix []byte
+	if hash != 0 {
+		var ok bool
+		if prefix, ok = rsaHashPrefixes[hash]; !ok {
+			return nil, errors.Errorf("unsupported hash %s for RSA PKCS #1 v1.5", hash)
+		}
+	}
+
+	return s.sign(pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil), append(prefix[:len(prefix):len(prefix)], digest...))
+}
+
+func (s *KeySigner) sign(mech *pkcs11.Mechanism, data []byte) ([]byte, error) {
+	p := s.token
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	err := p.ctx.SignInit(p.session, []*pkcs11.Mechanism{mech}, s.key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to initialise signing")
+	}
+
+	sig, err := p.ctx.Sign(p.session, data)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to sign")
+	}
+
+	return sig, nil
+}
+
+func (p *p11Token) PublicKey(label string, keyid string) (crypto.PublicKey, error) {
+	object, err := p.findKey(pkcs11.CKO_PUBLIC_KEY, label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.publicKey(object)
+}
+
+// publicKey reads the public key object key.
+func (p *p11Token) publicKey(key pkcs11.ObjectHandle) (crypto.PublicKey, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get key type")
+	}
+
+	return p.publicKeyOfType(key, uint(readAttributeAsULong(attrs[0].Value)))
+}
+
+// publicKeyOfType reads the public key object key, which has the CKK_ key type keyType.
+func (p *p11Token) publicKeyOfType(key pkcs11.ObjectHandle, keyType uint) (crypto.PublicKey, error) {
+	switch keyType {
+	case pkcs11.CKK_EC:
+		return p.ecPublicKey(key)
+	case pkcs11.CKK_RSA:
+		return p.rsaPublicKey(key)
+	case ckkECEdwards:
+		return p.edPublicKey(key)
+	default:
+		keyTypeName, _ := keyTypeToString(keyType)
+		return nil, errors.Errorf("unsupported key type %s", keyTypeName)
+	}
+}
+
+func (p *p11Token) ecPublicKey(key pkcs11.ObjectHandle) (*ecdsa.PublicKey, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get EC params")
+	}
+
+	curveName, err := curveFromParams(attrs[0].Value)
+	if err != nil {
+		return nil, err
+	}
+
+	point := ecPoint(p.ctx, p.session, key)
+
+	var curve elliptic.Curve
+	switch curveName {
+	case CurveSecp256k1:
+		return ethcrypto.UnmarshalPubkey(point)
+	case CurveP256:
+		curve = elliptic.P256()
+	case CurveP384:
+		curve = elliptic.P384()
+	default:
+		return nil, errors.Errorf("unsupported curve %s for an EC key", curveName)
+	}
+
+	x, y := elliptic.Unmarshal(curve, point)
+	if x == nil {
+		return nil, errors.New("invalid EC point")
+	}
+
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+}
+
+func (p *p11Token) edPublicKey(key pkcs11.ObjectHandle) (ed25519.PublicKey, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get EC point")
+	}
+
+	point := decodeEdwardsPoint(attrs[0].Value)
+	if len(point) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+
+	return ed25519.PublicKey(point), nil
+}
+
+func (p *p11Token) rsaPublicKey(key pkcs11.ObjectHandle) (*rsa.PublicKey, error) {
+	attrs, err := p.ctx.GetAttributeValue(p.session, key, []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+		pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+	})
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to get RSA public key")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(attrs[0].Value),
+		E: int(new(big.Int).SetBytes(attrs[1].Value).Int64()),
+	}, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/asn1"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+const signerPrivateHandle = pkcs11.ObjectHandle(1)
+const signerPublicHandle = pkcs11.ObjectHandle(2)
+
+// expectSignerLookup sets up the calls made by Signer to find a key pair of the given type.
+func expectSignerLookup(mockTokenCtx *MockTokenCtx, session pkcs11.SessionHandle, keyType uint) {
+	var calls []*gomock.Call
+	calls = append(calls, expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, signerPrivateHandle)...)
+	calls = append(calls, mockTokenCtx.EXPECT().GetAttributeValue(session, signerPrivateHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType)}, nil))
+	calls = append(calls, expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PUBLIC_KEY, signerPublicHandle)...)
+	gomock.InOrder(calls...)
+}
+
+func TestP11Token_Signer_EC(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	digest := sha256.Sum256([]byte("testmessage"))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	require.NoError(t, err)
+	rawSig := append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	expectSignerLookup(mockTokenCtx, session, pkcs11.CKK_EC)
+
+	params, _ := asn1.Marshal(p256OID)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, signerPublicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, nil)}}).
+		Return([]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params)}, nil)
+	mockTokenCtx.EXPECT().GetAttributeValue(session, signerPublicHandle,
+		attributeMatcher{[]*pkcs11.Attribute{pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, nil)}}).
+		Return([]*pkcs11.Attribute{ecPointAttribute(elliptic.Marshal(elliptic.P256(), key.X, key.Y))}, nil)
+
+	mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)},
+		signerPrivateHandle).Return(nil)
+	mockTokenCtx.EXPECT().Sign(session, digest[:]).Return(rawSig, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signer, err := p11Token.Signer(keyLabel, "")
+	require.NoError(t, err)
+	require.True(t, key.PublicKey.Equal(signer.Public()))
+
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signature))
+}
+
+func TestP11Token_Signer_RSA(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const keyLabel = "somekey"
+	digest := sha256.Sum256([]byte("testmessage"))
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	expected, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	expectSignerLookup(mockTokenCtx, session, pkcs11.CKK_RSA)
+
+	mockTokenCtx.EXPECT().GetAttributeValue(session, signerPublicHandle, gomock.Any()).
+		Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, key.N.Bytes()),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, big.NewInt(int64(key.E)).Bytes()),
+		}, nil)
+
+	mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS, nil)},
+		signerPrivateHandle).Return(nil)
+	mockTokenCtx.EXPECT().Sign(session, append(rsaHashPrefixes[crypto.SHA256], digest[:]...)).Return(expected, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	signer, err := p11Token.Signer(keyLabel, "")
+	require.NoError(t, err)
+	require.True(t, key.PublicKey.Equal(signer.Public()))
+
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	require.NoError(t, err)
+	require.NoError(t, rsa.VerifyPKCS1v15(&key.PublicKey, crypto.SHA256, digest[:], signature))
+}
+
+func TestP11Token_SignConcurrently(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const goroutines = 8
+	var active int32
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().SignInit(session, gomock.Any(), signerPrivateHandle).
+		Do(func(pkcs11.SessionHandle, []*pkcs11.Mechanism, pkcs11.ObjectHandle) {
+			if !atomic.CompareAndSwapInt32(&active, 0, 1) {
+				t.Error("signing operations overlapped")
+			}
+			time.Sleep(time.Millisecond)
+		}).Return(nil).Times(goroutines)
+	mockTokenCtx.EXPECT().Sign(session, gomock.Any()).
+		Do(func(pkcs11.SessionHandle, []byte) {
+			atomic.StoreInt32(&active, 0)
+		}).Return([]byte("signature"), nil).Times(goroutines)
+
+	///////////////// START TEST /////////////////
+
+	token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+	p := token.(*p11Token)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.signRaw(signerPrivateHandle, pkcs11.CKM_ECDSA, make([]byte, 32)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,86 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+	"github.com/pkg/errors"
+)
+
+// ParseTypedData decodes an EIP-712 JSON document. Numeric domain values, such as the usual "chainId": 1, are accepted
+// as well as the strings which apitypes.TypedData requires.
+func ParseTypedData(data []byte) (typedData apitypes.TypedData, err error) {
+	var doc map[string]json.RawMessage
+	if err = json.Unmarshal(data, &doc); err != nil {
+		return typedData, errors.WithMessage(err, "invalid typed data")
+	}
+
+	if rawDomain, ok := doc["domain"]; ok {
+		decoder := json.NewDecoder(bytes.NewReader(rawDomain))
+		decoder.UseNumber()
+
+		var domain map[string]interface{}
+		if err = decoder.Decode(&domain); err != nil {
+			return typedData, errors.WithMessage(err, "invalid typed data domain")
+		}
+		for k, v := range domain {
+			if n, ok := v.(json.Number); ok {
+				domain[k] = n.String()
+			}
+		}
+
+		if doc["domain"], err = json.Marshal(domain); err != nil {
+			return typedData, err
+		}
+		if data, err = json.Marshal(doc); err != nil {
+			return typedData, err
+		}
+	}
+
+	err = errors.WithMessage(json.Unmarshal(data, &typedData), "invalid typed data")
+	return typedData, err
+}
+
+// TypedDataHash returns the EIP-712 hash of typedData, keccak256("\x19\x01" || domainSeparator || hashStruct(message)).
+// This is the hash signed by eth_signTypedData_v4.
+func TypedDataHash(typedData apitypes.TypedData) ([]byte, error) {
+	domainSeparator, err := typedData.HashStruct("EIP712Domain", typedData.Domain.Map())
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to hash domain")
+	}
+
+	messageHash, err := typedData.HashStruct(typedData.PrimaryType, typedData.Message)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to hash message")
+	}
+
+	rawData := []byte(fmt.Sprintf("\x19\x01%s%s", string(domainSeparator), string(messageHash)))
+	return crypto.Keccak256(rawData), nil
+}
+
+func (p *p11Token) SignTypedData(label string, keyid string, typedData apitypes.TypedData) (signature []byte, err error) {
+	hash, err := TypedDataHash(typedData)
+	if err != nil {
+		return nil, err
+	}
+
+	return p.Sign(label, keyid, hash)
+}
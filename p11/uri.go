@@ -0,0 +1,146 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+const uriScheme = "pkcs11:"
+
+// URI holds the attributes of a PKCS#11 URI (RFC 7512) used to find a token and an object on it, for example
+// pkcs11:token=dimo;object=clitest;id=%01;type=private?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=/etc/pin
+// Attributes not listed here are ignored.
+type URI struct {
+	// Token attributes
+	Token        string
+	Manufacturer string
+	Model        string
+	Serial       string
+
+	// Object attributes. ID holds the raw CKA_ID bytes.
+	Object string
+	ID     string
+	Type   string
+
+	// Query attributes
+	ModulePath string
+	PINSource  string
+	PINValue   string
+}
+
+// objectTypes maps the valid values of the type attribute to object classes.
+var objectTypes = map[string]uint{
+	"cert":       pkcs11.CKO_CERTIFICATE,
+	"data":       pkcs11.CKO_DATA,
+	"private":    pkcs11.CKO_PRIVATE_KEY,
+	"public":     pkcs11.CKO_PUBLIC_KEY,
+	"secret-key": pkcs11.CKO_SECRET_KEY,
+}
+
+// ParseURI parses a PKCS#11 URI, percent-decoding its values.
+func ParseURI(uri string) (*URI, error) {
+	if !strings.HasPrefix(strings.ToLower(uri), uriScheme) {
+		return nil, errors.Errorf("URI must start with '%s'", uriScheme)
+	}
+
+	path, query, _ := strings.Cut(uri[len(uriScheme):], "?")
+
+	res := &URI{}
+	pathAttrs := map[string]*string{
+		"token":        &res.Token,
+		"manufacturer": &res.Manufacturer,
+		"model":        &res.Model,
+		"serial":       &res.Serial,
+		"object":       &res.Object,
+		"id":           &res.ID,
+		"type":         &res.Type,
+	}
+	queryAttrs := map[string]*string{
+		"module-path": &res.ModulePath,
+		"pin-source":  &res.PINSource,
+		"pin-value":   &res.PINValue,
+	}
+
+	if err := parseURIAttributes(path, ";", pathAttrs); err != nil {
+		return nil, err
+	}
+	if err := parseURIAttributes(query, "&", queryAttrs); err != nil {
+		return nil, err
+	}
+
+	if _, ok := objectTypes[res.Type]; res.Type != "" && !ok {
+		return nil, errors.Errorf("invalid object type '%s'", res.Type)
+	}
+	if res.PINSource != "" && res.PINValue != "" {
+		return nil, errors.New("URI must not have both pin-source and pin-value")
+	}
+
+	return res, nil
+}
+
+// TokenSpec returns the token attributes of the URI.
+func (u *URI) TokenSpec() TokenSpec {
+	return TokenSpec{
+		Label:        u.Token,
+		Serial:       u.Serial,
+		Manufacturer: u.Manufacturer,
+		Model:        u.Model,
+	}
+}
+
+// Class returns the object class given by the type attribute, or nil if there is none.
+func (u *URI) Class() *uint {
+	class, ok := objectTypes[u.Type]
+	if !ok {
+		return nil
+	}
+	return &class
+}
+
+func parseURIAttributes(s, sep string, attrs map[string]*string) error {
+	if s == "" {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	for _, attr := range strings.Split(s, sep) {
+		name, value, ok := strings.Cut(attr, "=")
+		if !ok || name == "" {
+			return errors.Errorf("invalid URI attribute '%s'", attr)
+		}
+
+		if seen[name] {
+			return errors.Errorf("duplicate URI attribute '%s'", name)
+		}
+		seen[name] = true
+
+		decoded, err := url.PathUnescape(value)
+		if err != nil {
+			return errors.WithMessagef(err, "invalid value for URI attribute '%s'", name)
+		}
+
+		if dest, ok := attrs[name]; ok {
+			*dest = decoded
+		}
+	}
+
+	return nil
+}
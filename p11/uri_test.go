@@ -0,0 +1,89 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"testing"
+
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseURI(t *testing.T) {
+	uri, err := ParseURI("pkcs11:token=dimo;serial=1234%20ab;object=clitest;id=%01%02;type=private" +
+		"?module-path=/usr/lib/softhsm/libsofthsm2.so&pin-source=file:/etc/pin&x-vendor=ignored")
+	require.NoError(t, err)
+
+	require.Equal(t, &URI{
+		Token:      "dimo",
+		Serial:     "1234 ab",
+		Object:     "clitest",
+		ID:         "\x01\x02",
+		Type:       "private",
+		ModulePath: "/usr/lib/softhsm/libsofthsm2.so",
+		PINSource:  "file:/etc/pin",
+	}, uri)
+
+	require.Equal(t, TokenSpec{Label: "dimo", Serial: "1234 ab"}, uri.TokenSpec())
+
+	require.NotNil(t, uri.Class())
+	require.Equal(t, uint(pkcs11.CKO_PRIVATE_KEY), *uri.Class())
+	require.Nil(t, (&URI{}).Class())
+}
+
+func TestParseURI_Invalid(t *testing.T) {
+	for _, uri := range []string{
+		"token=dimo",
+		"pkcs11:token=dimo;token=other",
+		"pkcs11:object",
+		"pkcs11:type=key",
+		"pkcs11:id=%zz",
+		"pkcs11:token=dimo?pin-value=1234&pin-source=/etc/pin",
+	} {
+		_, err := ParseURI(uri)
+		require.Error(t, err, uri)
+	}
+}
+
+func TestFindSlotWithToken(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	mockTokenCtx := NewMockTokenCtx(mockCtrl)
+
+	// Two tokens sharing a label
+	mockTokenCtx.EXPECT().GetSlotList(true).Return([]uint{1, 2}, nil).AnyTimes()
+	mockTokenCtx.EXPECT().GetTokenInfo(uint(1)).Return(pkcs11.TokenInfo{Label: tokenLabel, SerialNumber: "aaaa",
+		ManufacturerID: "SoftHSM project", Model: "SoftHSM v2"}, nil).AnyTimes()
+	mockTokenCtx.EXPECT().GetTokenInfo(uint(2)).Return(pkcs11.TokenInfo{Label: tokenLabel, SerialNumber: "bbbb",
+		ManufacturerID: "SoftHSM project", Model: "SoftHSM v2"}, nil).AnyTimes()
+
+	slot, err := findSlotWithToken(mockTokenCtx, TokenSpec{Label: tokenLabel})
+	require.NoError(t, err)
+	require.Equal(t, uint(1), slot)
+
+	slot, err = findSlotWithToken(mockTokenCtx, TokenSpec{Label: tokenLabel, Serial: "bbbb"})
+	require.NoError(t, err)
+	require.Equal(t, uint(2), slot)
+
+	slot, err = findSlotWithToken(mockTokenCtx, TokenSpec{Serial: "bbbb", Model: "SoftHSM v2"})
+	require.NoError(t, err)
+	require.Equal(t, uint(2), slot)
+
+	_, err = findSlotWithToken(mockTokenCtx, TokenSpec{Label: tokenLabel, Serial: "cccc"})
+	require.EqualError(t, err, "cannot find token label=someToken, serial=cccc")
+}
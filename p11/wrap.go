@@ -0,0 +1,350 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"math/big"
+	"strings"
+
+	"github.com/miekg/pkcs11"
+	"github.com/pkg/errors"
+)
+
+// WrapMechanism is a key wrapping mechanism supported by WrapKey and UnwrapKey.
+type WrapMechanism string
+
+// Supported wrapping mechanisms
+const (
+	// WrapAESKeyWrapPad is AES key wrap with padding (RFC 5649) under an AES key, for secret and private keys
+	WrapAESKeyWrapPad WrapMechanism = "aes-key-wrap-pad"
+	// WrapRSAOAEP is RSA-OAEP with SHA-256 and MGF1 with SHA-256, for secret keys. Keys are wrapped with the public
+	// key and unwrapped with the private key.
+	WrapRSAOAEP WrapMechanism = "rsa-oaep"
+)
+
+// wrapMechanisms holds the classes of the wrapping and unwrapping keys for each mechanism.
+var wrapMechanisms = map[WrapMechanism]struct {
+	wrapClass   uint
+	unwrapClass uint
+}{
+	WrapAESKeyWrapPad: {pkcs11.CKO_SECRET_KEY, pkcs11.CKO_SECRET_KEY},
+	WrapRSAOAEP:       {pkcs11.CKO_PUBLIC_KEY, pkcs11.CKO_PRIVATE_KEY},
+}
+
+// unwrapKeyTypes maps the key types accepted by UnwrapKey to the class and PKCS #11 type of the unwrapped key, and the
+// usages it is given, which are those of generated keys of the type.
+var unwrapKeyTypes = map[string]struct {
+	class   uint
+	keyType uint
+	usages  []uint
+}{
+	"AES": {pkcs11.CKO_SECRET_KEY, pkcs11.CKK_AES,
+		[]uint{pkcs11.CKA_ENCRYPT, pkcs11.CKA_DECRYPT, pkcs11.CKA_WRAP, pkcs11.CKA_UNWRAP}},
+	"GENERIC": {pkcs11.CKO_SECRET_KEY, pkcs11.CKK_GENERIC_SECRET, []uint{pkcs11.CKA_SIGN, pkcs11.CKA_VERIFY}},
+	"EC":      {pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_EC, []uint{pkcs11.CKA_SIGN, pkcs11.CKA_DERIVE}},
+	"ED25519": {pkcs11.CKO_PRIVATE_KEY, ckkECEdwards, []uint{pkcs11.CKA_SIGN}},
+	"RSA":     {pkcs11.CKO_PRIVATE_KEY, pkcs11.CKK_RSA, []uint{pkcs11.CKA_SIGN, pkcs11.CKA_DECRYPT, pkcs11.CKA_UNWRAP}},
+}
+
+// ParseWrapMechanism returns the mechanism with the given name: aes-key-wrap-pad or rsa-oaep.
+func ParseWrapMechanism(name string) (WrapMechanism, error) {
+	mechanism := WrapMechanism(name)
+	if _, ok := wrapMechanisms[mechanism]; !ok {
+		return "", errors.Errorf("unsupported wrapping mechanism '%s'", name)
+	}
+	return mechanism, nil
+}
+
+// WrapKey exports the secret or private key with the given label and/or key id, encrypted under the wrapping key.
+// The key must be extractable.
+func (p *p11Token) WrapKey(wrappingLabel string, wrappingKeyid string, mechanism WrapMechanism, label string,
+	keyid string) ([]byte, error) {
+	m, ok := wrapMechanisms[mechanism]
+	if !ok {
+		return nil, errors.Errorf("unsupported wrapping mechanism '%s'", mechanism)
+	}
+
+	wrappingKey, err := p.findKey(m.wrapClass, wrappingLabel, wrappingKeyid)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to find wrapping key")
+	}
+
+	key, err := p.findWrappableKey(label, keyid)
+	if err != nil {
+		return nil, err
+	}
+
+	mech := wrapMechanism(mechanism)
+
+	wrapped, err := p.ctx.WrapKey(p.session, []*pkcs11.Mechanism{mech}, wrappingKey, key)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to wrap key")
+	}
+
+	return wrapped, nil
+}
+
+// UnwrapKey decrypts a key wrapped by WrapKey and stores it on the token with the given label and key id. keytype
+// is AES, GENERIC, EC, ED25519 or RSA, the last three being private keys, which get a public key object alongside.
+// RSA public keys are read from the private key; EC and Ed25519 ones are not held in the wrapped key, so publicKey
+// must give the key pair's public key, in any of the encodings accepted by DeriveKey or, for Ed25519, as written by
+// ExportPublicKey. It is checked against the unwrapped key.
+func (p *p11Token) UnwrapKey(unwrappingLabel string, unwrappingKeyid string, mechanism WrapMechanism,
+	wrapped []byte, label string, keyid string, keytype string, publicKey []byte, extractable bool) error {
+	m, ok := wrapMechanisms[mechanism]
+	if !ok {
+		return errors.Errorf("unsupported wrapping mechanism '%s'", mechanism)
+	}
+
+	kt, ok := unwrapKeyTypes[keytype]
+	if !ok {
+		return errors.Errorf("Invalid key type: %s", keytype)
+	}
+	if (kt.keyType == pkcs11.CKK_EC || kt.keyType == ckkECEdwards) && len(publicKey) == 0 {
+		return errors.Errorf("the public key is needed to unwrap an %s key", keytype)
+	}
+
+	unwrappingKey, err := p.findKey(m.unwrapClass, unwrappingLabel, unwrappingKeyid)
+	if err != nil {
+		return errors.WithMessage(err, "failed to find unwrapping key")
+	}
+
+	if keyid == "" {
+		keyid = label
+	}
+
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, kt.class),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, kt.keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyid),
+		pkcs11.NewAttribute(pkcs11.CKA_SENSITIVE, true),
+		pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, extractable),
+	}
+	if kt.class == pkcs11.CKO_PRIVATE_KEY {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_PRIVATE, true))
+	}
+	for _, usage := range kt.usages {
+		template = append(template, pkcs11.NewAttribute(usage, true))
+	}
+
+	mech := wrapMechanism(mechanism)
+
+	key, err := p.ctx.UnwrapKey(p.session, []*pkcs11.Mechanism{mech}, unwrappingKey, wrapped, template)
+	if err != nil {
+		return errors.WithMessage(err, "failed to unwrap key")
+	}
+
+	if kt.class == pkcs11.CKO_PRIVATE_KEY {
+		if err := p.createPublicKey(key, kt.keyType, publicKey, label, keyid); err != nil {
+			if destroyErr := p.ctx.DestroyObject(p.session, key); destroyErr != nil {
+				return errors.WithMessagef(err, "failed to remove unwrapped key %s (%s)", label, destroyErr)
+			}
+			return err
+		}
+	}
+
+	return nil
+}
+
+// createPublicKey creates the public key object of the unwrapped private key object, with the same label and key id.
+func (p *p11Token) createPublicKey(private pkcs11.ObjectHandle, keyType uint, publicKey []byte, label string,
+	keyid string) error {
+	template := []*pkcs11.Attribute{
+		pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+		pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, keyType),
+		pkcs11.NewAttribute(pkcs11.CKA_TOKEN, true),
+		pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		pkcs11.NewAttribute(pkcs11.CKA_LABEL, label),
+		pkcs11.NewAttribute(pkcs11.CKA_ID, keyid),
+	}
+
+	switch keyType {
+	case pkcs11.CKK_RSA:
+		attrs, err := p.ctx.GetAttributeValue(p.session, private, []*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, nil),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, nil),
+		})
+		if err != nil {
+			return errors.WithMessage(err, "failed to read RSA public key")
+		}
+		template = append(template, attrs...)
+	case ckkECEdwards:
+		pub, err := parseEd25519PublicKey(publicKey)
+		if err != nil {
+			return err
+		}
+
+		if err := p.checkKeyPair(private, pub); err != nil {
+			return err
+		}
+
+		marshaledOID, _ := asn1.Marshal(ed25519OID)
+		marshaledPoint, _ := asn1.Marshal([]byte(pub))
+		template = append(template,
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, marshaledOID),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, marshaledPoint))
+	default:
+		curve, err := p.keyCurve(private)
+		if err != nil {
+			return err
+		}
+
+		point, err := parsePeerPoint(curve, publicKey)
+		if err != nil {
+			return errors.WithMessage(err, "invalid public key")
+		}
+
+		pub, err := unmarshalPoint(curve, point)
+		if err != nil {
+			return err
+		}
+
+		if err := p.checkKeyPair(private, pub); err != nil {
+			return err
+		}
+
+		marshaledOID, _ := asn1.Marshal(curveOIDs[curve])
+		marshaledPoint, _ := asn1.Marshal(point)
+		template = append(template,
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, marshaledOID),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, marshaledPoint))
+	}
+
+	if _, err := p.ctx.CreateObject(p.session, template); err != nil {
+		return errors.WithMessage(err, "failed to create public key")
+	}
+
+	return nil
+}
+
+// checkKeyPair signs a random hash with the EC or Ed25519 private key object and verifies it with pub.
+func (p *p11Token) checkKeyPair(private pkcs11.ObjectHandle, pub crypto.PublicKey) error {
+	hash := make([]byte, 32)
+	if _, err := rand.Read(hash); err != nil {
+		return err
+	}
+
+	var verified bool
+	switch pub := pub.(type) {
+	case ed25519.PublicKey:
+		sig, err := p.signRaw(private, ckmEdDSA, hash)
+		if err != nil {
+			return err
+		}
+		verified = ed25519.Verify(pub, hash, sig)
+	case *ecdsa.PublicKey:
+		sig, err := p.signRaw(private, pkcs11.CKM_ECDSA, hash)
+		if err != nil {
+			return err
+		}
+		size := len(sig) / 2
+		r, s := new(big.Int).SetBytes(sig[:size]), new(big.Int).SetBytes(sig[size:])
+		verified = ecdsa.Verify(pub, hash, r, s)
+	default:
+		return errors.Errorf("unsupported public key type %T", pub)
+	}
+
+	if !verified {
+		return errors.New("the public key does not belong to the unwrapped key")
+	}
+	return nil
+}
+
+// parseEd25519PublicKey returns the Ed25519 public key in data, as hex or a PEM, DER or JWK public key.
+func parseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	text := bytes.TrimSpace(data)
+
+	var key []byte
+	if hexKey, err := hex.DecodeString(strings.TrimPrefix(string(text), "0x")); err == nil {
+		key = hexKey
+	} else if bytes.HasPrefix(text, []byte("{")) {
+		var k jwk
+		if err := json.Unmarshal(text, &k); err != nil || k.Kty != "OKP" || k.Crv != "Ed25519" {
+			return nil, errors.New("public key is not an Ed25519 JWK")
+		}
+		if key, err = base64.RawURLEncoding.DecodeString(k.X); err != nil {
+			return nil, errors.WithMessage(err, "invalid JWK x")
+		}
+	} else {
+		der := data
+		if block, _ := pem.Decode(text); block != nil {
+			der = block.Bytes
+		}
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			return nil, errors.WithMessage(err, "invalid public key")
+		}
+		edPub, ok := pub.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("public key is not an Ed25519 key")
+		}
+		key = edPub
+	}
+
+	if len(key) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid Ed25519 public key")
+	}
+	return key, nil
+}
+
+// findWrappableKey returns the single secret key, or failing that private key, with the label and/or key id provided.
+func (p *p11Token) findWrappableKey(label string, keyid string) (pkcs11.ObjectHandle, error) {
+	var template []*pkcs11.Attribute
+	if label != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_LABEL, label))
+	}
+	if keyid != "" {
+		template = append(template, pkcs11.NewAttribute(pkcs11.CKA_ID, keyid))
+	}
+
+	for _, class := range []uint{pkcs11.CKO_SECRET_KEY, pkcs11.CKO_PRIVATE_KEY} {
+		objects, err := p.findAllMatching(append([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, class)}, template...))
+		if err != nil {
+			return 0, err
+		}
+		if len(objects) > 1 {
+			return 0, errors.New("More than 1 matching key found, please specify both label and key id")
+		}
+		if len(objects) == 1 {
+			return objects[0], nil
+		}
+	}
+
+	return 0, errors.New("No matching keys found")
+}
+
+// wrapMechanism returns the PKCS #11 mechanism for mechanism.
+func wrapMechanism(mechanism WrapMechanism) *pkcs11.Mechanism {
+	if mechanism == WrapRSAOAEP {
+		params := pkcs11.NewOAEPParams(pkcs11.CKM_SHA256, pkcs11.CKG_MGF1_SHA256, pkcs11.CKZ_DATA_SPECIFIED, nil)
+		return pkcs11.NewMechanism(pkcs11.CKM_RSA_PKCS_OAEP, params)
+	}
+	return pkcs11.NewMechanism(pkcs11.CKM_AES_KEY_WRAP_PAD, nil)
+}
@@ -0,0 +1,284 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/asn1"
+	"encoding/hex"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+func TestP11Token_WrapKey(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const wrappingHandle = pkcs11.ObjectHandle(1)
+	const keyHandle = pkcs11.ObjectHandle(2)
+	wrapped := []byte("wrapped key")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, wrappingHandle)...)
+	gomock.InOrder(expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, keyHandle)...)
+	mockTokenCtx.EXPECT().WrapKey(session, gomock.Any(), wrappingHandle, keyHandle).
+		Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _, _ pkcs11.ObjectHandle) {
+			require.Equal(t, uint(pkcs11.CKM_AES_KEY_WRAP_PAD), m[0].Mechanism)
+		}).Return(wrapped, nil)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	result, err := p11Token.WrapKey("kek", "", WrapAESKeyWrapPad, "cachekey", "")
+	require.NoError(t, err)
+	require.Equal(t, wrapped, result)
+}
+
+func TestP11Token_UnwrapKey(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const unwrappingHandle = pkcs11.ObjectHandle(1)
+	wrapped := []byte("wrapped key")
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, unwrappingHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().UnwrapKey(session, gomock.Any(), unwrappingHandle, wrapped,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_AES),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "cachekey"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, "cachekey"),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+		}}).
+		Do(func(_ pkcs11.SessionHandle, m []*pkcs11.Mechanism, _ pkcs11.ObjectHandle, _ []byte,
+			_ []*pkcs11.Attribute) {
+			require.Equal(t, uint(pkcs11.CKM_RSA_PKCS_OAEP), m[0].Mechanism)
+		}).Return(pkcs11.ObjectHandle(3), nil))
+
+	// HMAC keys are restored as generic secret keys, which can sign and verify
+	calls = append(calls, expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_PRIVATE_KEY, unwrappingHandle)...)
+	calls = append(calls, mockTokenCtx.EXPECT().UnwrapKey(session, gomock.Any(), unwrappingHandle, wrapped,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_SECRET_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_GENERIC_SECRET),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, true),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_VERIFY, true),
+		}}).Return(pkcs11.ObjectHandle(4), nil))
+	gomock.InOrder(calls...)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	err = p11Token.UnwrapKey("migration", "", WrapRSAOAEP, wrapped, "cachekey", "", "AES", nil, false)
+	require.NoError(t, err)
+
+	err = p11Token.UnwrapKey("migration", "", WrapRSAOAEP, wrapped, "hmackey", "", "GENERIC", nil, true)
+	require.NoError(t, err)
+
+	err = p11Token.UnwrapKey("migration", "", WrapRSAOAEP, wrapped, "cachekey", "", "DES", nil, false)
+	require.Error(t, err)
+}
+
+func TestP11Token_UnwrapKeyEC(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	// expectECSign uses handles 1 and 2 for the key pair
+	const unwrappingHandle = pkcs11.ObjectHandle(10)
+	const privateHandle = pkcs11.ObjectHandle(1)
+	wrapped := []byte("wrapped key")
+	hash := crypto.Keccak256([]byte("testmessage"))
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	pub := crypto.FromECDSAPub(&key.PublicKey)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, unwrappingHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().UnwrapKey(session, gomock.Any(), unwrappingHandle, wrapped,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_EC),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "restored"),
+		}}).Return(privateHandle, nil))
+	calls = append(calls, expectKeyCurve(mockTokenCtx, session, privateHandle, pkcs11.CKK_EC, secp256k1OID)...)
+	calls = append(calls,
+		mockTokenCtx.EXPECT().SignInit(session, gomock.Any(), privateHandle).Return(nil),
+		mockTokenCtx.EXPECT().Sign(session, gomock.Any()).DoAndReturn(
+			func(_ pkcs11.SessionHandle, data []byte) ([]byte, error) {
+				sig, err := crypto.Sign(data, key)
+				return sig[:64], err
+			}),
+		mockTokenCtx.EXPECT().CreateObject(session, attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "restored"),
+			pkcs11.NewAttribute(pkcs11.CKA_ID, "restored"),
+			ecPointAttribute(pub),
+		}}).Return(pkcs11.ObjectHandle(2), nil))
+	gomock.InOrder(calls...)
+
+	// Signing with the restored key needs its public key object
+	expected := expectECSign(t, mockTokenCtx, session, key, hash)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	err = p11Token.UnwrapKey("kek", "", WrapAESKeyWrapPad, wrapped, "restored", "", "EC", nil, false)
+	require.Error(t, err, "EC keys need the public key")
+
+	err = p11Token.UnwrapKey("kek", "", WrapAESKeyWrapPad, wrapped, "restored", "", "EC",
+		[]byte(hex.EncodeToString(pub)), false)
+	require.NoError(t, err)
+
+	signature, err := p11Token.Sign("restored", "", hash)
+	require.NoError(t, err)
+	require.Equal(t, expected, signature)
+}
+
+func TestP11Token_UnwrapKeyRSA(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const unwrappingHandle = pkcs11.ObjectHandle(10)
+	const privateHandle = pkcs11.ObjectHandle(1)
+	wrapped := []byte("wrapped key")
+	modulus := []byte{0xc3, 0x5f, 0x01}
+	exponent := []byte{1, 0, 1}
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	// Restored RSA keys can decrypt and unwrap, e.g. as an RSA-OAEP key-encryption key
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, unwrappingHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().UnwrapKey(session, gomock.Any(), unwrappingHandle, wrapped,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_EXTRACTABLE, false),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+			pkcs11.NewAttribute(pkcs11.CKA_DECRYPT, true),
+			pkcs11.NewAttribute(pkcs11.CKA_UNWRAP, true),
+		}}).Return(privateHandle, nil))
+	calls = append(calls,
+		mockTokenCtx.EXPECT().GetAttributeValue(session, privateHandle, gomock.Any()).Return([]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, modulus),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, exponent),
+		}, nil),
+		mockTokenCtx.EXPECT().CreateObject(session, attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, pkcs11.CKK_RSA),
+			pkcs11.NewAttribute(pkcs11.CKA_LABEL, "migration"),
+			pkcs11.NewAttribute(pkcs11.CKA_MODULUS, modulus),
+			pkcs11.NewAttribute(pkcs11.CKA_PUBLIC_EXPONENT, exponent),
+		}}).Return(pkcs11.ObjectHandle(2), nil))
+	gomock.InOrder(calls...)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	err = p11Token.UnwrapKey("kek", "", WrapAESKeyWrapPad, wrapped, "migration", "", "RSA", nil, false)
+	require.NoError(t, err)
+}
+
+func TestP11Token_UnwrapKeyEd25519(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	const unwrappingHandle = pkcs11.ObjectHandle(10)
+	const privateHandle = pkcs11.ObjectHandle(1)
+	wrapped := []byte("wrapped key")
+
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	params, _ := asn1.Marshal(ed25519OID)
+	point, _ := asn1.Marshal([]byte(pub))
+	pemKey, err := ExportPublicKey(pub, PublicKeyPEM)
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	calls := expectFindAllMatching(mockTokenCtx, session, pkcs11.CKO_SECRET_KEY, unwrappingHandle)
+	calls = append(calls, mockTokenCtx.EXPECT().UnwrapKey(session, gomock.Any(), unwrappingHandle, wrapped,
+		attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PRIVATE_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkECEdwards),
+			pkcs11.NewAttribute(pkcs11.CKA_SIGN, true),
+		}}).Return(privateHandle, nil))
+	calls = append(calls,
+		mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(ckmEdDSA, nil)},
+			privateHandle).Return(nil),
+		mockTokenCtx.EXPECT().Sign(session, gomock.Any()).DoAndReturn(
+			func(_ pkcs11.SessionHandle, data []byte) ([]byte, error) {
+				return ed25519.Sign(key, data), nil
+			}),
+		mockTokenCtx.EXPECT().CreateObject(session, attributeMatcher{[]*pkcs11.Attribute{
+			pkcs11.NewAttribute(pkcs11.CKA_CLASS, pkcs11.CKO_PUBLIC_KEY),
+			pkcs11.NewAttribute(pkcs11.CKA_KEY_TYPE, ckkECEdwards),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_PARAMS, params),
+			pkcs11.NewAttribute(pkcs11.CKA_EC_POINT, point),
+		}}).Return(pkcs11.ObjectHandle(2), nil))
+	gomock.InOrder(calls...)
+
+	///////////////// START TEST /////////////////
+
+	p11Token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+
+	err = p11Token.UnwrapKey("kek", "", WrapAESKeyWrapPad, wrapped, "signing", "", "ED25519", pemKey, false)
+	require.NoError(t, err)
+}
+
+func TestParseEd25519PublicKey(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	for _, format := range []PublicKeyFormat{PublicKeyPEM, PublicKeyDER, PublicKeyJWK, PublicKeyHex} {
+		encoded, err := ExportPublicKey(pub, format)
+		require.NoError(t, err, format)
+
+		parsed, err := parseEd25519PublicKey(encoded)
+		require.NoError(t, err, format)
+		require.Equal(t, pub, parsed, format)
+	}
+
+	_, err = parseEd25519PublicKey([]byte("0102"))
+	require.Error(t, err)
+
+	ecKey, err := crypto.GenerateKey()
+	require.NoError(t, err)
+	encoded, err := ExportPublicKey(&ecKey.PublicKey, PublicKeyJWK)
+	require.NoError(t, err)
+	_, err = parseEd25519PublicKey(encoded)
+	require.Error(t, err)
+}
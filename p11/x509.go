@@ -0,0 +1,156 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+
+	"github.com/pkg/errors"
+)
+
+// oidSignatureECDSAWithSHA256 is ecdsa-with-SHA256 from RFC 5758.
+var oidSignatureECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+
+// CreateCertificateRequest returns a DER encoded PKCS #10 request for template, signed by signer. Unlike
+// x509.CreateCertificateRequest it supports secp256k1 key pairs, which sign with ecdsa-with-SHA256.
+func CreateCertificateRequest(template *x509.CertificateRequest, signer *KeySigner) ([]byte, error) {
+	if !isSecp256k1(signer.Public()) {
+		return x509.CreateCertificateRequest(rand.Reader, template, signer)
+	}
+
+	if err := checkSecp256k1SignatureAlgorithm(template.SignatureAlgorithm); err != nil {
+		return nil, err
+	}
+
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, template, placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceKeyAndSign(der, &placeholder.PublicKey, signer)
+}
+
+// CreateSelfSignedCertificate returns a DER encoded certificate for template, issued by and for the key pair of
+// signer. secp256k1 key pairs are supported as for CreateCertificateRequest.
+func CreateSelfSignedCertificate(template *x509.Certificate, signer *KeySigner) ([]byte, error) {
+	if !isSecp256k1(signer.Public()) {
+		return x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	}
+
+	if err := checkSecp256k1SignatureAlgorithm(template.SignatureAlgorithm); err != nil {
+		return nil, err
+	}
+
+	// x509 would derive the subject key id of CA certificates from the placeholder key
+	cpy := *template
+	if len(cpy.SubjectKeyId) == 0 && cpy.IsCA {
+		pub := signer.Public().(*ecdsa.PublicKey)
+		id := sha1.Sum(elliptic.Marshal(pub.Curve, pub.X, pub.Y))
+		cpy.SubjectKeyId = id[:]
+	}
+
+	placeholder, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &cpy, &cpy, &placeholder.PublicKey, placeholder)
+	if err != nil {
+		return nil, err
+	}
+
+	return replaceKeyAndSign(der, &placeholder.PublicKey, signer)
+}
+
+func checkSecp256k1SignatureAlgorithm(algorithm x509.SignatureAlgorithm) error {
+	if algorithm != x509.UnknownSignatureAlgorithm && algorithm != x509.ECDSAWithSHA256 {
+		return errors.Errorf("secp256k1 keys can only sign with %s", x509.ECDSAWithSHA256)
+	}
+	return nil
+}
+
+// replaceKeyAndSign replaces the SubjectPublicKeyInfo of placeholder in der, a certificate or certificate request
+// created by the x509 package with placeholder's private key, with that of signer, and signs the result again with
+// signer using ecdsa-with-SHA256. Both structures are a SEQUENCE of the signed data, which holds the
+// SubjectPublicKeyInfo as one of its fields, the signature algorithm and the signature.
+func replaceKeyAndSign(der []byte, placeholder crypto.PublicKey, signer crypto.Signer) ([]byte, error) {
+	var signed struct {
+		Data      asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}
+	if rest, err := asn1.Unmarshal(der, &signed); err != nil || len(rest) > 0 {
+		return nil, errors.New("failed to parse the created structure")
+	}
+
+	oldKey, err := x509.MarshalPKIXPublicKey(placeholder)
+	if err != nil {
+		return nil, err
+	}
+	newKey, err := marshalPublicKey(signer.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	var fields []byte
+	replaced := false
+	for rest := signed.Data.Bytes; len(rest) > 0; {
+		var field asn1.RawValue
+		if rest, err = asn1.Unmarshal(rest, &field); err != nil {
+			return nil, errors.WithMessage(err, "failed to parse the created structure")
+		}
+
+		if !replaced && bytes.Equal(field.FullBytes, oldKey) {
+			field.FullBytes = newKey
+			replaced = true
+		}
+		fields = append(fields, field.FullBytes...)
+	}
+	if !replaced {
+		return nil, errors.New("public key not found in the created structure")
+	}
+
+	data, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSequence, IsCompound: true,
+		Bytes: fields})
+	if err != nil {
+		return nil, err
+	}
+
+	digest := sha256.Sum256(data)
+	signature, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	signed.Data = asn1.RawValue{FullBytes: data}
+	signed.Algorithm = pkix.AlgorithmIdentifier{Algorithm: oidSignatureECDSAWithSHA256}
+	signed.Signature = asn1.BitString{Bytes: signature, BitLength: 8 * len(signature)}
+	return asn1.Marshal(signed)
+}
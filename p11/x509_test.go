@@ -0,0 +1,94 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package p11
+
+import (
+	"crypto/ecdsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/golang/mock/gomock"
+	"github.com/miekg/pkcs11"
+	"github.com/stretchr/testify/require"
+)
+
+// requireSignedWithSecp256k1 checks der is a certificate or request holding key's public key and signed by it.
+func requireSignedWithSecp256k1(t *testing.T, der []byte, key *ecdsa.PrivateKey) {
+	var signed struct {
+		Data      asn1.RawValue
+		Algorithm pkix.AlgorithmIdentifier
+		Signature asn1.BitString
+	}
+	rest, err := asn1.Unmarshal(der, &signed)
+	require.NoError(t, err)
+	require.Empty(t, rest)
+	require.True(t, signed.Algorithm.Algorithm.Equal(oidSignatureECDSAWithSHA256))
+
+	spki, err := marshalPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	require.Contains(t, string(signed.Data.Bytes), string(spki))
+
+	digest := sha256.Sum256(signed.Data.FullBytes)
+	require.True(t, ecdsa.VerifyASN1(&key.PublicKey, digest[:], signed.Signature.Bytes))
+}
+
+func TestCreateCertificateRequest_Secp256k1(t *testing.T) {
+	mockCtrl, mockTokenCtx, session := prepMockForLogin(t)
+	defer mockCtrl.Finish()
+
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	///////////////// MOCK EXPECTATIONS /////////////////
+
+	mockTokenCtx.EXPECT().SignInit(session, []*pkcs11.Mechanism{pkcs11.NewMechanism(pkcs11.CKM_ECDSA, nil)},
+		signerPrivateHandle).Return(nil).Times(2)
+	mockTokenCtx.EXPECT().Sign(session, gomock.Any()).DoAndReturn(
+		func(_ pkcs11.SessionHandle, digest []byte) ([]byte, error) {
+			sig, err := crypto.Sign(digest, key)
+			return sig[:64], err
+		}).Times(2)
+
+	///////////////// START TEST /////////////////
+
+	token, err := newP11Token(mockTokenCtx, tokenLabel, tokenPIN)
+	require.Nil(t, err)
+	signer := &KeySigner{token: token.(*p11Token), key: signerPrivateHandle, publicKey: &key.PublicKey}
+
+	der, err := CreateCertificateRequest(&x509.CertificateRequest{Subject: pkix.Name{CommonName: "device"}}, signer)
+	require.NoError(t, err)
+	requireSignedWithSecp256k1(t, der, key)
+
+	der, err = CreateSelfSignedCertificate(&x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "device"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(time.Hour),
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}, signer)
+	require.NoError(t, err)
+	requireSignedWithSecp256k1(t, der, key)
+
+	_, err = CreateCertificateRequest(&x509.CertificateRequest{SignatureAlgorithm: x509.ECDSAWithSHA384}, signer)
+	require.Error(t, err)
+}
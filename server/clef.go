@@ -0,0 +1,110 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// externalAPIVersion is the version of Clef's external API implemented by the account_* methods.
+const externalAPIVersion = "6.1.0"
+
+// validatorData is the data for the EIP-191 version 0 (intended validator) content type.
+type validatorData struct {
+	Address common.Address `json:"address"`
+	Message hexutil.Bytes  `json:"message"`
+}
+
+// registerClef registers the account_* methods of Clef's external API, so that the server can be used as the --signer
+// of geth and similar tools.
+func (s *Server) registerClef() {
+	s.register("account_list", s.ethAccounts)
+	s.register("account_signTransaction", s.accountSignTransaction)
+	s.register("account_signData", s.accountSignData)
+	s.register("account_signTypedData", s.accountSignTypedData)
+	s.register("account_version", s.accountVersion)
+}
+
+// accountSignTransaction signs a transaction: account_signTransaction(args, methodSelector). The method selector is
+// only used by Clef to display the call, and is ignored.
+func (s *Server) accountSignTransaction(params []json.RawMessage) (interface{}, error) {
+	var args apitypes.SendTxArgs
+	var methodSelector *string
+	if err := decodeParams(params, 1, &args, &methodSelector); err != nil {
+		return nil, err
+	}
+
+	return s.signTransaction(args)
+}
+
+// accountSignData signs data of the given content type: account_signData(contentType, address, data). The
+// text/plain, data/typed and data/validator content types are supported.
+func (s *Server) accountSignData(params []json.RawMessage) (interface{}, error) {
+	var contentType string
+	var address common.Address
+	var raw json.RawMessage
+	if err := decodeParams(params, 0, &contentType, &address, &raw); err != nil {
+		return nil, err
+	}
+
+	switch contentType {
+	case accounts.MimetypeTextPlain:
+		var data hexutil.Bytes
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, &rpcError{codeInvalidParams, "text/plain data must be hex encoded"}
+		}
+		return s.signText(address, data)
+
+	case accounts.MimetypeTypedData:
+		typedData, err := decodeTypedData(raw)
+		if err != nil {
+			return nil, err
+		}
+		return s.signTypedData(address, typedData)
+
+	case accounts.MimetypeDataWithValidator:
+		var data validatorData
+		if err := json.Unmarshal(raw, &data); err != nil {
+			return nil, &rpcError{codeInvalidParams, "invalid validator data: " + err.Error()}
+		}
+
+		// EIP-191 version 0: keccak256(0x19 || 0x00 || validator address || message)
+		hash := crypto.Keccak256([]byte{0x19, 0x00}, data.Address.Bytes(), data.Message)
+		signature, err := s.wallet.SignHash(accounts.Account{Address: address}, hash)
+		if err != nil {
+			return nil, err
+		}
+		return hexutil.Bytes(toLegacyV(signature)), nil
+
+	default:
+		return nil, &rpcError{codeInvalidParams, "unsupported content type " + contentType}
+	}
+}
+
+// accountSignTypedData signs EIP-712 typed data: account_signTypedData(address, typedData).
+func (s *Server) accountSignTypedData(params []json.RawMessage) (interface{}, error) {
+	return s.ethSignTypedDataV4(params)
+}
+
+func (s *Server) accountVersion(params []json.RawMessage) (interface{}, error) {
+	return externalAPIVersion, nil
+}
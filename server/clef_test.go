@@ -0,0 +1,105 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"net"
+	"path/filepath"
+	"testing"
+
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer_AccountSignData(t *testing.T) {
+	srv, address := newTestServer(t)
+	message := []byte("testmessage")
+
+	var signature hexutil.Bytes
+	require.Nil(t, call(t, srv, "account_signData", &signature, accounts.MimetypeTextPlain, address,
+		hexutil.Bytes(message)))
+	requireSignedBy(t, address, accounts.TextHash(message), signature)
+
+	validator := common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB")
+	require.Nil(t, call(t, srv, "account_signData", &signature, accounts.MimetypeDataWithValidator, address,
+		validatorData{Address: validator, Message: message}))
+	requireSignedBy(t, address, crypto.Keccak256([]byte{0x19, 0x00}, validator.Bytes(), message), signature)
+
+	rpcErr := call(t, srv, "account_signData", nil, accounts.MimetypeClique, address, hexutil.Bytes(message))
+	require.NotNil(t, rpcErr)
+	require.Equal(t, codeInvalidParams, rpcErr.Code)
+}
+
+func TestServer_AccountSignTypedData(t *testing.T) {
+	srv, address := newTestServer(t)
+
+	var signature hexutil.Bytes
+	require.Nil(t, call(t, srv, "account_signTypedData", &signature, address, json.RawMessage(mailTypedData)))
+	requireSignedBy(t, address, mailTypedDataHash, signature)
+
+	require.Nil(t, call(t, srv, "account_signData", &signature, accounts.MimetypeTypedData, address,
+		json.RawMessage(mailTypedData)))
+	requireSignedBy(t, address, mailTypedDataHash, signature)
+}
+
+func TestServer_ServeIPC(t *testing.T) {
+	srv, address := newTestServer(t)
+
+	listener, err := net.Listen("unix", filepath.Join(t.TempDir(), "signer.ipc"))
+	require.NoError(t, err)
+	defer listener.Close()
+
+	go srv.Config.Handler.(*Server).ServeIPC(listener)
+
+	conn, err := net.Dial("unix", listener.Addr().String())
+	require.NoError(t, err)
+	defer conn.Close()
+
+	encoder := json.NewEncoder(conn)
+	decoder := json.NewDecoder(conn)
+
+	// Two requests on the same connection, the first a batch
+	require.NoError(t, encoder.Encode([]map[string]interface{}{
+		{"jsonrpc": "2.0", "id": 1, "method": "account_version"},
+		{"jsonrpc": "2.0", "id": 2, "method": "account_list"},
+	}))
+
+	var batch []struct {
+		ID     int             `json:"id"`
+		Result json.RawMessage `json:"result"`
+	}
+	require.NoError(t, decoder.Decode(&batch))
+	require.Len(t, batch, 2)
+	require.JSONEq(t, `"`+externalAPIVersion+`"`, string(batch[0].Result))
+
+	var addresses []common.Address
+	require.NoError(t, json.Unmarshal(batch[1].Result, &addresses))
+	require.Equal(t, []common.Address{address}, addresses)
+
+	require.NoError(t, encoder.Encode(map[string]interface{}{"jsonrpc": "2.0", "id": 3, "method": "account_list"}))
+
+	var single struct {
+		ID     int              `json:"id"`
+		Result []common.Address `json:"result"`
+	}
+	require.NoError(t, decoder.Decode(&single))
+	require.Equal(t, 3, single.ID)
+	require.Equal(t, []common.Address{address}, single.Result)
+}
@@ -0,0 +1,171 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package server
+
+import (
+	"encoding/json"
+	"math/big"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/signer/core/apitypes"
+)
+
+// signTransactionResult is the result of eth_signTransaction, as returned by geth.
+type signTransactionResult struct {
+	Raw hexutil.Bytes      `json:"raw"`
+	Tx  *types.Transaction `json:"tx"`
+}
+
+func (s *Server) registerEth() {
+	s.register("eth_accounts", s.ethAccounts)
+	s.register("eth_sign", s.ethSign)
+	s.register("personal_sign", s.personalSign)
+	s.register("eth_signTransaction", s.ethSignTransaction)
+	s.register("eth_signTypedData_v4", s.ethSignTypedDataV4)
+}
+
+// ethAccounts returns the addresses of the key pairs on the token.
+func (s *Server) ethAccounts(params []json.RawMessage) (interface{}, error) {
+	addresses := []common.Address{}
+	for _, account := range s.wallet.Accounts() {
+		addresses = append(addresses, account.Address)
+	}
+	return addresses, nil
+}
+
+// ethSign signs the EIP-191 hash of data: eth_sign(address, data).
+func (s *Server) ethSign(params []json.RawMessage) (interface{}, error) {
+	var address common.Address
+	var data hexutil.Bytes
+	if err := decodeParams(params, 0, &address, &data); err != nil {
+		return nil, err
+	}
+
+	return s.signText(address, data)
+}
+
+// personalSign signs the EIP-191 hash of data: personal_sign(data, address, password). The password is ignored as
+// the token is already logged in.
+func (s *Server) personalSign(params []json.RawMessage) (interface{}, error) {
+	var data hexutil.Bytes
+	var address common.Address
+	var password string
+	if err := decodeParams(params, 1, &data, &address, &password); err != nil {
+		return nil, err
+	}
+
+	return s.signText(address, data)
+}
+
+// ethSignTransaction signs, but does not send, a transaction from the account in its from field.
+func (s *Server) ethSignTransaction(params []json.RawMessage) (interface{}, error) {
+	var args apitypes.SendTxArgs
+	if err := decodeParams(params, 0, &args); err != nil {
+		return nil, err
+	}
+
+	return s.signTransaction(args)
+}
+
+// ethSignTypedDataV4 signs the EIP-712 hash of typed data: eth_signTypedData_v4(address, typedData). The typed data
+// may be given as an object or as a JSON string.
+func (s *Server) ethSignTypedDataV4(params []json.RawMessage) (interface{}, error) {
+	var address common.Address
+	var raw json.RawMessage
+	if err := decodeParams(params, 0, &address, &raw); err != nil {
+		return nil, err
+	}
+
+	typedData, err := decodeTypedData(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.signTypedData(address, typedData)
+}
+
+func (s *Server) signText(address common.Address, data []byte) (hexutil.Bytes, error) {
+	signature, err := s.wallet.SignText(accounts.Account{Address: address}, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return toLegacyV(signature), nil
+}
+
+func (s *Server) signTypedData(address common.Address, typedData apitypes.TypedData) (hexutil.Bytes, error) {
+	hash, err := p11.TypedDataHash(typedData)
+	if err != nil {
+		return nil, &rpcError{codeInvalidParams, err.Error()}
+	}
+
+	signature, err := s.wallet.SignHash(accounts.Account{Address: address}, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toLegacyV(signature), nil
+}
+
+func (s *Server) signTransaction(args apitypes.SendTxArgs) (*signTransactionResult, error) {
+	chainID := s.chainID
+	if args.ChainID != nil {
+		chainID = args.ChainID.ToInt()
+	}
+	if chainID == nil {
+		return nil, &rpcError{codeInvalidParams, "chainId is required"}
+	}
+	if args.GasPrice == nil && args.MaxFeePerGas == nil {
+		return nil, &rpcError{codeInvalidParams, "gasPrice or maxFeePerGas is required"}
+	}
+
+	account := accounts.Account{Address: args.From.Address()}
+	signed, err := s.wallet.SignTx(account, args.ToTransaction(), new(big.Int).Set(chainID))
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := signed.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+
+	return &signTransactionResult{Raw: raw, Tx: signed}, nil
+}
+
+// decodeTypedData accepts EIP-712 typed data either as a JSON object or as a string holding one, as sent by
+// different wallets, with numeric or string domain values.
+func decodeTypedData(raw json.RawMessage) (typedData apitypes.TypedData, err error) {
+	var str string
+	if json.Unmarshal(raw, &str) == nil {
+		raw = json.RawMessage(str)
+	}
+
+	if typedData, err = p11.ParseTypedData(raw); err != nil {
+		err = &rpcError{codeInvalidParams, err.Error()}
+	}
+	return
+}
+
+// toLegacyV converts V from 0/1 to the 27/28 returned by eth_sign and most wallets.
+func toLegacyV(signature []byte) []byte {
+	signature[64] += 27
+	return signature
+}
@@ -0,0 +1,323 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package server implements a JSON-RPC 2.0 signing service over HTTP or IPC, backed by a PKCS#11 wallet. It lets
+// several processes share one logged in token session. Both the eth_* signing methods and the account_* methods of
+// Clef's external API are served.
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/DIMO-Network/edge-identity/wallet"
+	"github.com/pkg/errors"
+)
+
+// JSON-RPC 2.0 error codes.
+const (
+	codeParseError     = -32700
+	codeInvalidRequest = -32600
+	codeMethodNotFound = -32601
+	codeInvalidParams  = -32602
+	codeServerError    = -32000
+)
+
+// maxRequestSize limits the size of a request body.
+const maxRequestSize = 1 << 20
+
+type request struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+type response struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *rpcError) Error() string {
+	return e.Message
+}
+
+// method handles a call with the given (positional) params.
+type method func(params []json.RawMessage) (interface{}, error)
+
+// HTTPOptions restricts the HTTP requests which are served, so that web pages cannot reach the signer through the
+// browser. Only requests for a loopback host or one of VHosts are accepted, and only browser requests from one of
+// Origins. If Token is set, every request must also carry it as a bearer token. "*" in VHosts or Origins allows any.
+type HTTPOptions struct {
+	VHosts  []string
+	Origins []string
+	Token   string
+}
+
+// Server serves the signing methods over JSON-RPC 2.0, either as an http.Handler or with ServeIPC. Batches are
+// supported.
+type Server struct {
+	wallet  *wallet.Wallet
+	chainID *big.Int
+	http    HTTPOptions
+	methods map[string]method
+}
+
+// New returns a server signing with the accounts in w. chainID is used to sign transactions which do not carry
+// one, and may be nil. opts applies to HTTP requests only.
+func New(w *wallet.Wallet, chainID *big.Int, opts HTTPOptions) *Server {
+	s := &Server{
+		wallet:  w,
+		chainID: chainID,
+		http:    opts,
+		methods: make(map[string]method),
+	}
+
+	s.registerEth()
+	s.registerClef()
+
+	return s
+}
+
+func (s *Server) register(name string, m method) {
+	s.methods[name] = m
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if status, err := s.checkHTTP(r); err != nil {
+		if status == http.StatusUnauthorized {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result := s.handleMessage(body)
+	if result == nil {
+		// Only notifications
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		log.Printf("Failed to write response: %s", err)
+	}
+}
+
+// checkHTTP applies the HTTPOptions to r, returning the status to fail it with.
+func (s *Server) checkHTTP(r *http.Request) (int, error) {
+	host := r.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if ip := net.ParseIP(strings.Trim(host, "[]")); !strings.EqualFold(host, "localhost") &&
+		(ip == nil || !ip.IsLoopback()) && !allowed(s.http.VHosts, host) {
+		return http.StatusForbidden, errors.New("invalid host specified")
+	}
+
+	if origin := r.Header.Get("Origin"); origin != "" && !allowed(s.http.Origins, origin) {
+		return http.StatusForbidden, errors.New("origin not allowed")
+	}
+
+	if mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type")); err != nil ||
+		mediaType != "application/json" {
+		return http.StatusUnsupportedMediaType, errors.New("content type must be application/json")
+	}
+
+	if s.http.Token != "" {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(token), []byte(s.http.Token)) != 1 {
+			return http.StatusUnauthorized, errors.New("missing or invalid bearer token")
+		}
+	}
+
+	return http.StatusOK, nil
+}
+
+// allowed reports whether value is in list, ignoring case, or list holds "*".
+func allowed(list []string, value string) bool {
+	for _, entry := range list {
+		if entry == "*" || strings.EqualFold(entry, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// ServeIPC accepts connections on listener and serves a stream of JSON-RPC messages on each, as geth does on its IPC
+// endpoint. It returns when the listener is closed.
+func (s *Server) ServeIPC(listener net.Listener) error {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		go s.serveConn(conn)
+	}
+}
+
+func (s *Server) serveConn(conn net.Conn) {
+	defer conn.Close()
+
+	decoder := json.NewDecoder(conn)
+	encoder := json.NewEncoder(conn)
+	for {
+		var msg json.RawMessage
+		if err := decoder.Decode(&msg); err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("Closing connection: %s", err)
+			}
+			return
+		}
+
+		result := s.handleMessage(msg)
+		if result == nil {
+			continue
+		}
+		if err := encoder.Encode(result); err != nil {
+			log.Printf("Failed to write response: %s", err)
+			return
+		}
+	}
+}
+
+// handleMessage handles a single call or a batch, returning the response(s) to send or nil if there are none.
+func (s *Server) handleMessage(body []byte) interface{} {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 || body[0] != '[' {
+		if resp := s.handle(body); resp != nil {
+			return resp
+		}
+		return nil
+	}
+
+	var batch []json.RawMessage
+	if err := json.Unmarshal(body, &batch); err != nil {
+		return errorResponse(nil, &rpcError{codeParseError, err.Error()})
+	}
+	if len(batch) == 0 {
+		return errorResponse(nil, &rpcError{codeInvalidRequest, "empty batch"})
+	}
+
+	var responses []*response
+	for _, raw := range batch {
+		if resp := s.handle(raw); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if responses == nil {
+		return nil
+	}
+	return responses
+}
+
+// handle runs a single call, returning nil for notifications.
+func (s *Server) handle(raw json.RawMessage) *response {
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return errorResponse(nil, &rpcError{codeParseError, err.Error()})
+	}
+
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return errorResponse(req.ID, &rpcError{codeInvalidRequest, "invalid request"})
+	}
+
+	m, ok := s.methods[req.Method]
+	if !ok {
+		return errorResponse(req.ID, &rpcError{codeMethodNotFound, "the method " + req.Method + " does not exist"})
+	}
+
+	var params []json.RawMessage
+	if len(req.Params) > 0 {
+		if err := json.Unmarshal(req.Params, &params); err != nil {
+			return errorResponse(req.ID, &rpcError{codeInvalidParams, "params must be an array"})
+		}
+	}
+
+	result, err := m(params)
+	if req.ID == nil {
+		return nil
+	}
+	if err != nil {
+		log.Printf("%s failed: %s", req.Method, err)
+
+		var rpcErr *rpcError
+		if !errors.As(err, &rpcErr) {
+			rpcErr = &rpcError{codeServerError, err.Error()}
+		}
+		return errorResponse(req.ID, rpcErr)
+	}
+
+	return &response{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+func errorResponse(id json.RawMessage, err *rpcError) *response {
+	if id == nil {
+		id = json.RawMessage("null")
+	}
+	return &response{JSONRPC: "2.0", ID: id, Error: err}
+}
+
+// decodeParams unmarshals params into args in order. Trailing params may be omitted if optional is at least the
+// number missing; extra params are ignored.
+func decodeParams(params []json.RawMessage, optional int, args ...interface{}) error {
+	if len(params) < len(args)-optional {
+		return &rpcError{codeInvalidParams, fmt.Sprintf("missing value for required argument %d", len(params))}
+	}
+
+	for i, arg := range args {
+		if i >= len(params) {
+			break
+		}
+		if err := json.Unmarshal(params[i], arg); err != nil {
+			return &rpcError{codeInvalidParams, fmt.Sprintf("invalid argument %d: %s", i, err)}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/DIMO-Network/edge-identity/wallet"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/common/hexutil"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeToken signs with an in-memory key, returning signatures in the same form as the PKCS#11 token.
+type fakeToken struct {
+	p11.Token
+	key *ecdsa.PrivateKey
+}
+
+func (f *fakeToken) ECKeyPairs() ([]p11.KeyPair, error) {
+	return []p11.KeyPair{{Label: "clitest", KeyID: "01", PublicKey: &f.key.PublicKey}}, nil
+}
+
+func (f *fakeToken) Sign(label, keyid string, hash []byte) ([]byte, error) {
+	signature, err := crypto.Sign(hash, f.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27
+	return signature, nil
+}
+
+func (f *fakeToken) SignPersonal(label, keyid string, message []byte) ([]byte, error) {
+	return f.Sign(label, keyid, accounts.TextHash(message))
+}
+
+func (f *fakeToken) SignTx(label, keyid string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), f.key)
+}
+
+func newTestServer(t *testing.T) (*httptest.Server, common.Address) {
+	return newTestServerWithOptions(t, HTTPOptions{})
+}
+
+func newTestServerWithOptions(t *testing.T, opts HTTPOptions) (*httptest.Server, common.Address) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	w, err := wallet.NewWallet(&fakeToken{key: key}, "dimo")
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(New(w, big.NewInt(137), opts))
+	t.Cleanup(srv.Close)
+
+	return srv, crypto.PubkeyToAddress(key.PublicKey)
+}
+
+// call makes a JSON-RPC request and decodes the result into result, returning any error object.
+func call(t *testing.T, srv *httptest.Server, method string, result interface{}, params ...interface{}) *rpcError {
+	body, err := json.Marshal(map[string]interface{}{"jsonrpc": "2.0", "id": 1, "method": method, "params": params})
+	require.NoError(t, err)
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader(string(body)))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var r struct {
+		Result json.RawMessage `json:"result"`
+		Error  *rpcError       `json:"error"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+
+	if r.Error == nil && result != nil {
+		require.NoError(t, json.Unmarshal(r.Result, result))
+	}
+	return r.Error
+}
+
+// requireSignedBy checks signature, with V of 27 or 28, recovers to address.
+func requireSignedBy(t *testing.T, address common.Address, hash []byte, signature hexutil.Bytes) {
+	require.Len(t, signature, crypto.SignatureLength)
+	require.Contains(t, []byte{27, 28}, signature[64])
+
+	signature[64] -= 27
+	pub, err := crypto.SigToPub(hash, signature)
+	require.NoError(t, err)
+	require.Equal(t, address, crypto.PubkeyToAddress(*pub))
+}
+
+func TestServer_EthAccounts(t *testing.T) {
+	srv, address := newTestServer(t)
+
+	var addresses []common.Address
+	require.Nil(t, call(t, srv, "eth_accounts", &addresses))
+	require.Equal(t, []common.Address{address}, addresses)
+}
+
+func TestServer_Sign(t *testing.T) {
+	srv, address := newTestServer(t)
+	message := []byte("testmessage")
+
+	var signature hexutil.Bytes
+	require.Nil(t, call(t, srv, "eth_sign", &signature, address, hexutil.Bytes(message)))
+	requireSignedBy(t, address, accounts.TextHash(message), signature)
+
+	require.Nil(t, call(t, srv, "personal_sign", &signature, hexutil.Bytes(message), address))
+	requireSignedBy(t, address, accounts.TextHash(message), signature)
+
+	rpcErr := call(t, srv, "eth_sign", nil, common.HexToAddress("0x01"), hexutil.Bytes(message))
+	require.NotNil(t, rpcErr)
+	require.Equal(t, codeServerError, rpcErr.Code)
+
+	rpcErr = call(t, srv, "eth_sign", nil, address)
+	require.NotNil(t, rpcErr)
+	require.Equal(t, codeInvalidParams, rpcErr.Code)
+}
+
+func TestServer_SignTransaction(t *testing.T) {
+	srv, address := newTestServer(t)
+
+	args := map[string]interface{}{
+		"from":                 address,
+		"to":                   common.HexToAddress("0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"),
+		"gas":                  "0x5208",
+		"maxFeePerGas":         "0x9502f9000",
+		"maxPriorityFeePerGas": "0x77359400",
+		"value":                "0x1",
+		"nonce":                "0x3",
+	}
+
+	var result struct {
+		Raw hexutil.Bytes `json:"raw"`
+	}
+	require.Nil(t, call(t, srv, "eth_signTransaction", &result, args))
+
+	tx := new(types.Transaction)
+	require.NoError(t, tx.UnmarshalBinary(result.Raw))
+	require.Equal(t, uint8(types.DynamicFeeTxType), tx.Type())
+	require.Equal(t, int64(137), tx.ChainId().Int64())
+
+	sender, err := types.Sender(types.LatestSignerForChainID(tx.ChainId()), tx)
+	require.NoError(t, err)
+	require.Equal(t, address, sender)
+}
+
+// mailTypedData is the example from the EIP-712 specification, with the numeric chainId sent by most wallets.
+const mailTypedData = `{
+  "types": {
+    "EIP712Domain": [
+      {"name": "name", "type": "string"},
+      {"name": "version", "type": "string"},
+      {"name": "chainId", "type": "uint256"},
+      {"name": "verifyingContract", "type": "address"}
+    ],
+    "Person": [
+      {"name": "name", "type": "string"},
+      {"name": "wallet", "type": "address"}
+    ],
+    "Mail": [
+      {"name": "from", "type": "Person"},
+      {"name": "to", "type": "Person"},
+      {"name": "contents", "type": "string"}
+    ]
+  },
+  "primaryType": "Mail",
+  "domain": {
+    "name": "Ether Mail",
+    "version": "1",
+    "chainId": 1,
+    "verifyingContract": "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"
+  },
+  "message": {
+    "from": {"name": "Cow", "wallet": "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"},
+    "to": {"name": "Bob", "wallet": "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"},
+    "contents": "Hello, Bob!"
+  }
+}`
+
+// mailTypedDataHash is the EIP-712 hash of mailTypedData.
+var mailTypedDataHash = hexutil.MustDecode("0xbe609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2")
+
+func TestServer_SignTypedDataV4(t *testing.T) {
+	srv, address := newTestServer(t)
+
+	// MetaMask sends the typed data as a JSON string, ethers as an object
+	var signature hexutil.Bytes
+	require.Nil(t, call(t, srv, "eth_signTypedData_v4", &signature, address, mailTypedData))
+	requireSignedBy(t, address, mailTypedDataHash, signature)
+
+	require.Nil(t, call(t, srv, "eth_signTypedData_v4", &signature, address, json.RawMessage(mailTypedData)))
+	requireSignedBy(t, address, mailTypedDataHash, signature)
+
+	rpcErr := call(t, srv, "eth_signTypedData_v4", nil, address, `{"domain": {"chainId": 1.5}}`)
+	require.NotNil(t, rpcErr)
+	require.Equal(t, codeInvalidParams, rpcErr.Code)
+}
+
+func TestServer_UnknownMethod(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	rpcErr := call(t, srv, "eth_sendTransaction", nil)
+	require.NotNil(t, rpcErr)
+	require.Equal(t, codeMethodNotFound, rpcErr.Code)
+}
+
+func TestServer_EmptyBatch(t *testing.T) {
+	srv, _ := newTestServer(t)
+
+	resp, err := http.Post(srv.URL, "application/json", strings.NewReader("[]"))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var r response
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&r))
+	require.NotNil(t, r.Error)
+	require.Equal(t, codeInvalidRequest, r.Error.Code)
+}
+
+func TestServer_HTTPChecks(t *testing.T) {
+	srv, _ := newTestServerWithOptions(t, HTTPOptions{
+		VHosts:  []string{"signer.internal"},
+		Origins: []string{"https://app.dimo.zone"},
+		Token:   "secret",
+	})
+	body := `{"jsonrpc":"2.0","id":1,"method":"eth_accounts"}`
+
+	tests := []struct {
+		name    string
+		host    string
+		headers map[string]string
+		status  int
+	}{
+		{"ok", "", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"vhost", "signer.internal:8545", map[string]string{"Authorization": "Bearer secret"}, http.StatusOK},
+		{"allowed origin", "", map[string]string{"Authorization": "Bearer secret", "Origin": "https://app.dimo.zone"},
+			http.StatusOK},
+		{"host", "evil.example:8545", map[string]string{"Authorization": "Bearer secret"}, http.StatusForbidden},
+		{"origin", "", map[string]string{"Authorization": "Bearer secret", "Origin": "https://evil.example"},
+			http.StatusForbidden},
+		{"content type", "", map[string]string{"Authorization": "Bearer secret", "Content-Type": "text/plain"},
+			http.StatusUnsupportedMediaType},
+		{"no token", "", nil, http.StatusUnauthorized},
+		{"wrong token", "", map[string]string{"Authorization": "Bearer wrong"}, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, err := http.NewRequest(http.MethodPost, srv.URL, strings.NewReader(body))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			for name, value := range test.headers {
+				req.Header.Set(name, value)
+			}
+			if test.host != "" {
+				req.Host = test.host
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			require.Equal(t, test.status, resp.StatusCode)
+		})
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+// Package wallet exposes the secp256k1 key pairs on a PKCS#11 token as a go-ethereum accounts.Wallet, so that they
+// can be used with accounts.Manager and bind.TransactOpts. Signing happens on the token.
+package wallet
+
+import (
+	"math/big"
+	"sync"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	ethereum "github.com/ethereum/go-ethereum"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/pkg/errors"
+)
+
+// URLScheme is the scheme used in the URLs of PKCS#11 wallets and accounts.
+const URLScheme = "pkcs11"
+
+// Wallet is an accounts.Wallet with one account for each secp256k1 key pair on a token. The token must already be
+// logged in; Open and Close do not affect it.
+type Wallet struct {
+	token p11.Token
+	url   accounts.URL
+
+	// mu guards accounts and keyPairs. It is not held while the token is used, as the token serialises use of its
+	// session itself.
+	mu       sync.Mutex
+	accounts []accounts.Account
+	keyPairs map[common.Address]p11.KeyPair
+}
+
+// NewWallet returns a wallet for the key pairs on token. tokenLabel is only used to build the wallet and account
+// URLs.
+func NewWallet(token p11.Token, tokenLabel string) (*Wallet, error) {
+	w := &Wallet{
+		token: token,
+		url:   accounts.URL{Scheme: URLScheme, Path: tokenLabel},
+	}
+
+	if err := w.Refresh(); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Refresh reloads the key pairs from the token, picking up any generated since the wallet was created.
+func (w *Wallet) Refresh() error {
+	keyPairs, err := w.token.ECKeyPairs()
+	if err != nil {
+		return errors.WithMessage(err, "failed to list key pairs")
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.accounts = nil
+	w.keyPairs = make(map[common.Address]p11.KeyPair)
+	for _, kp := range keyPairs {
+		address := crypto.PubkeyToAddress(*kp.PublicKey)
+		if _, exists := w.keyPairs[address]; exists {
+			// The same key can't be found twice, but be defensive about duplicated objects
+			continue
+		}
+
+		w.keyPairs[address] = kp
+		w.accounts = append(w.accounts, accounts.Account{
+			Address: address,
+			URL:     accounts.URL{Scheme: URLScheme, Path: w.url.Path + "/" + kp.Label},
+		})
+	}
+
+	return nil
+}
+
+// URL implements accounts.Wallet.
+func (w *Wallet) URL() accounts.URL {
+	return w.url
+}
+
+// Status implements accounts.Wallet.
+func (w *Wallet) Status() (string, error) {
+	return "Open", nil
+}
+
+// Open implements accounts.Wallet. The token is logged in when created, so this does nothing.
+func (w *Wallet) Open(passphrase string) error {
+	return nil
+}
+
+// Close implements accounts.Wallet. The token is owned by the caller, so this does nothing.
+func (w *Wallet) Close() error {
+	return nil
+}
+
+// Accounts implements accounts.Wallet.
+func (w *Wallet) Accounts() []accounts.Account {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	cpy := make([]accounts.Account, len(w.accounts))
+	copy(cpy, w.accounts)
+	return cpy
+}
+
+// Contains implements accounts.Wallet.
+func (w *Wallet) Contains(account accounts.Account) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, ok := w.keyPairs[account.Address]
+	return ok
+}
+
+// Derive implements accounts.Wallet. Token keys are not hierarchical, so this is not supported.
+func (w *Wallet) Derive(path accounts.DerivationPath, pin bool) (accounts.Account, error) {
+	return accounts.Account{}, accounts.ErrNotSupported
+}
+
+// SelfDerive implements accounts.Wallet. Token keys are not hierarchical, so this does nothing.
+func (w *Wallet) SelfDerive(bases []accounts.DerivationPath, chain ethereum.ChainStateReader) {
+}
+
+// SignHash returns a signature over hash with V in [0, 1]. It is not part of accounts.Wallet, but mirrors the
+// keystore method of the same name.
+func (w *Wallet) SignHash(account accounts.Account, hash []byte) ([]byte, error) {
+	kp, err := w.keyPair(account)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := w.token.Sign(kp.Label, kp.KeyID, hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return toRecoveryID(signature), nil
+}
+
+// SignData implements accounts.Wallet. As with the keystore wallet, the keccak256 hash of data is signed regardless of
+// mimeType.
+func (w *Wallet) SignData(account accounts.Account, mimeType string, data []byte) ([]byte, error) {
+	return w.SignHash(account, crypto.Keccak256(data))
+}
+
+// SignDataWithPassphrase implements accounts.Wallet. The token is already logged in, so passphrase is ignored.
+func (w *Wallet) SignDataWithPassphrase(account accounts.Account, passphrase, mimeType string, data []byte) ([]byte, error) {
+	return w.SignData(account, mimeType, data)
+}
+
+// SignText implements accounts.Wallet, signing the EIP-191 hash of text. V is in [0, 1].
+func (w *Wallet) SignText(account accounts.Account, text []byte) ([]byte, error) {
+	kp, err := w.keyPair(account)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := w.token.SignPersonal(kp.Label, kp.KeyID, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return toRecoveryID(signature), nil
+}
+
+// SignTextWithPassphrase implements accounts.Wallet. The token is already logged in, so passphrase is ignored.
+func (w *Wallet) SignTextWithPassphrase(account accounts.Account, passphrase string, text []byte) ([]byte, error) {
+	return w.SignText(account, text)
+}
+
+// SignTx implements accounts.Wallet.
+func (w *Wallet) SignTx(account accounts.Account, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	kp, err := w.keyPair(account)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.token.SignTx(kp.Label, kp.KeyID, tx, chainID)
+}
+
+// SignTxWithPassphrase implements accounts.Wallet. The token is already logged in, so passphrase is ignored.
+func (w *Wallet) SignTxWithPassphrase(account accounts.Account, passphrase string, tx *types.Transaction,
+	chainID *big.Int) (*types.Transaction, error) {
+	return w.SignTx(account, tx, chainID)
+}
+
+// SignerFn returns a function that signs transactions for chainID. It can be used as the Signer of a
+// bind.TransactOpts.
+func (w *Wallet) SignerFn(chainID *big.Int) func(common.Address, *types.Transaction) (*types.Transaction, error) {
+	return func(address common.Address, tx *types.Transaction) (*types.Transaction, error) {
+		return w.SignTx(accounts.Account{Address: address}, tx, chainID)
+	}
+}
+
+// keyPair returns the key pair for account.
+func (w *Wallet) keyPair(account accounts.Account) (p11.KeyPair, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	kp, ok := w.keyPairs[account.Address]
+	if !ok {
+		return p11.KeyPair{}, accounts.ErrUnknownAccount
+	}
+	return kp, nil
+}
+
+// toRecoveryID converts the V of a token signature from 27/28 to the 0/1 returned by the other go-ethereum wallets.
+func toRecoveryID(signature []byte) []byte {
+	if len(signature) == crypto.SignatureLength && signature[64] >= 27 {
+		signature[64] -= 27
+	}
+	return signature
+}
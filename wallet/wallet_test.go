@@ -0,0 +1,141 @@
+// Copyright 2018 Thales UK Limited
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated
+// documentation files (the "Software"), to deal in the Software without restriction, including without limitation the
+// rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to
+// permit persons to whom the Software is furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all copies or substantial portions of the
+// Software.
+//
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE
+// WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR
+// COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR
+// OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+
+package wallet
+
+import (
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/DIMO-Network/edge-identity/p11"
+	"github.com/ethereum/go-ethereum/accounts"
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/core/types"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	tokenLabel = "dimo"
+	keyLabel   = "clitest"
+	keyID      = "01"
+)
+
+// fakeToken signs with an in-memory key, returning signatures in the same form as the PKCS#11 token.
+type fakeToken struct {
+	p11.Token
+	key *ecdsa.PrivateKey
+}
+
+func (f *fakeToken) ECKeyPairs() ([]p11.KeyPair, error) {
+	return []p11.KeyPair{{Label: keyLabel, KeyID: keyID, PublicKey: &f.key.PublicKey}}, nil
+}
+
+func (f *fakeToken) Sign(label, keyid string, hash []byte) ([]byte, error) {
+	signature, err := crypto.Sign(hash, f.key)
+	if err != nil {
+		return nil, err
+	}
+	signature[64] += 27
+	return signature, nil
+}
+
+func (f *fakeToken) SignPersonal(label, keyid string, message []byte) ([]byte, error) {
+	return f.Sign(label, keyid, accounts.TextHash(message))
+}
+
+func (f *fakeToken) SignTx(label, keyid string, tx *types.Transaction, chainID *big.Int) (*types.Transaction, error) {
+	return types.SignTx(tx, types.LatestSignerForChainID(chainID), f.key)
+}
+
+func newTestWallet(t *testing.T) (*Wallet, *ecdsa.PrivateKey) {
+	key, err := crypto.GenerateKey()
+	require.NoError(t, err)
+
+	w, err := NewWallet(&fakeToken{key: key}, tokenLabel)
+	require.NoError(t, err)
+
+	return w, key
+}
+
+func TestWallet_Accounts(t *testing.T) {
+	w, key := newTestWallet(t)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+
+	accts := w.Accounts()
+	require.Len(t, accts, 1)
+	require.Equal(t, address, accts[0].Address)
+	require.Equal(t, "pkcs11://dimo/clitest", accts[0].URL.String())
+
+	require.True(t, w.Contains(accounts.Account{Address: address}))
+	require.False(t, w.Contains(accounts.Account{Address: common.HexToAddress("0x01")}))
+
+	backend := NewBackend(w)
+	require.Len(t, backend.Wallets(), 1)
+}
+
+func TestWallet_SignText(t *testing.T) {
+	w, key := newTestWallet(t)
+	account := accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	text := []byte("hello world")
+
+	signature, err := w.SignText(account, text)
+	require.NoError(t, err)
+	require.Less(t, signature[64], byte(2))
+
+	pub, err := crypto.SigToPub(accounts.TextHash(text), signature)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(*pub))
+
+	_, err = w.SignText(accounts.Account{Address: common.HexToAddress("0x01")}, text)
+	require.ErrorIs(t, err, accounts.ErrUnknownAccount)
+}
+
+func TestWallet_SignData(t *testing.T) {
+	w, key := newTestWallet(t)
+	account := accounts.Account{Address: crypto.PubkeyToAddress(key.PublicKey)}
+	data := []byte{0xde, 0xad, 0xbe, 0xef}
+
+	signature, err := w.SignData(account, accounts.MimetypeTextPlain, data)
+	require.NoError(t, err)
+
+	pub, err := crypto.SigToPub(crypto.Keccak256(data), signature)
+	require.NoError(t, err)
+	require.Equal(t, account.Address, crypto.PubkeyToAddress(*pub))
+}
+
+func TestWallet_SignerFn(t *testing.T) {
+	w, key := newTestWallet(t)
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	chainID := big.NewInt(137)
+
+	tx := types.NewTx(&types.DynamicFeeTx{
+		ChainID:   chainID,
+		Nonce:     1,
+		GasTipCap: big.NewInt(1),
+		GasFeeCap: big.NewInt(2),
+		Gas:       21000,
+		To:        &common.Address{},
+		Value:     big.NewInt(1),
+	})
+
+	signed, err := w.SignerFn(chainID)(address, tx)
+	require.NoError(t, err)
+
+	sender, err := types.Sender(types.LatestSignerForChainID(chainID), signed)
+	require.NoError(t, err)
+	require.Equal(t, address, sender)
+}